@@ -0,0 +1,17 @@
+//go:build !darwin && !linux
+// +build !darwin,!linux
+
+package main // import "golang.handcraftedbits.com/ezif/cmd/ezifworker"
+
+import (
+	"fmt"
+	"runtime"
+)
+
+//
+// Private functions
+//
+
+func setMemoryLimit(limit uint64) error {
+	return fmt.Errorf("memory limits are not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,21 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package main // import "golang.handcraftedbits.com/ezif/cmd/ezifworker"
+
+import (
+	"syscall"
+)
+
+//
+// Private functions
+//
+
+// setMemoryLimit limits the address space of the process, which is the closest approximation of a memory limit that
+// works everywhere we need it to.
+func setMemoryLimit(limit uint64) error {
+	return syscall.Setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{
+		Cur: limit,
+		Max: limit,
+	})
+}
@@ -0,0 +1,48 @@
+package main // import "golang.handcraftedbits.com/ezif/cmd/ezifworker"
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"golang.handcraftedbits.com/ezif/metadata"
+)
+
+//
+// Private variables
+//
+
+var commandRoot = &cobra.Command{
+	Use:   "ezifworker",
+	Short: "ezifworker reads image metadata on behalf of metadata.IsolatedReader",
+	Long: "ezifworker reads image metadata on behalf of metadata.IsolatedReader, receiving requests on standard " +
+		"input and writing responses to standard output until standard input is closed.  It isn't meant to be run " +
+		"directly.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if flagMemoryLimit > 0 {
+			if err := setMemoryLimit(flagMemoryLimit); err != nil {
+				return errors.Wrap(err, "could not set memory limit")
+			}
+		}
+
+		return metadata.ServeIsolatedReads(os.Stdin, os.Stdout)
+	},
+}
+
+var (
+	flagMemoryLimit uint64
+)
+
+//
+// Private functions
+//
+
+func main() {
+	commandRoot.Flags().Uint64VarP(&flagMemoryLimit, "memory-limit", "m", 0, "maximum amount of memory, in bytes, "+
+		"that can be used (0 for no limit)")
+
+	if err := commandRoot.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"sort"
 	"strings"
 	"text/template"
+	"unicode"
 )
 
 //
@@ -13,10 +14,13 @@ import (
 //
 
 type accessorInfo struct {
-	ImplName string
-	IsSlice  bool
-	Name     string
-	Type     string
+	Decoder          string
+	DecoderByteOrder bool
+	ImplName         string
+	IsSlice          bool
+	Name             string
+	TextDecoder      string
+	Type             string
 }
 
 type accessorTemplateContext struct {
@@ -24,6 +28,13 @@ type accessorTemplateContext struct {
 	PackageName   string
 }
 
+type decodedAccessor struct {
+	decoder     string
+	byteOrder   bool
+	goType      string
+	textDecoder string
+}
+
 //
 // Private variables
 //
@@ -59,6 +70,21 @@ var accessors = map[string]string{
 	"UnsignedShortSlice":    "[]uint16",
 }
 
+// Accessors for properties whose raw []byte values have a structured layout that we decode into a richer type.  These
+// are associated with specific properties via the "accessorOverrides" section of the sourcegen configuration.  Some
+// properties are already decoded into strings by Exiv2 (e.g., comments), in which case the text decoder is used
+// instead.
+var decodedAccessors = map[string]decodedAccessor{
+	"CFAPattern":               {"types.ParseCFAPattern", true, "types.CFAPattern", ""},
+	"Comment":                  {"types.ParseComment", true, "types.Comment", "types.NewComment"},
+	"ComponentsConfiguration":  {"types.ParseComponentsConfiguration", false, "types.ComponentsConfiguration", ""},
+	"DeviceSettingDescription": {"types.ParseDeviceSettingDescription", true, "types.DeviceSettingDescription", ""},
+	"OECF":                     {"types.ParseOECF", true, "types.OECF", ""},
+	"SpatialFrequencyResponse": {"types.ParseSpatialFrequencyResponse", true, "types.SpatialFrequencyResponse", ""},
+	"Version":                  {"types.ParseVersion", false, "types.Version", ""},
+	"XPString":                 {"types.ParseXPString", false, "string", ""},
+}
+
 //
 // Private functions
 //
@@ -76,7 +102,7 @@ func generateAccessorSource(packageName string, templateBody string) (string, er
 	}
 
 	err = templateRoot.Execute(&buffer, &accessorTemplateContext{
-		AccessorInfos: getAccessorInfos(accessors),
+		AccessorInfos: getAccessorInfos(accessors, decodedAccessors),
 		PackageName:   packageName,
 	})
 
@@ -95,27 +121,51 @@ func generateAccessorSource(packageName string, templateBody string) (string, er
 	return string(formattedSource), nil
 }
 
-func getAccessorInfos(accessors map[string]string) []accessorInfo {
-	var result []accessorInfo
+func getAccessorImplName(name string) string {
+	var nameRunes = []rune(name)
+	var upperCount = 0
 
-	for name, goType := range accessors {
-		var implName string
-		var nameRunes = []rune(name)
+	for upperCount < len(nameRunes) && unicode.IsUpper(nameRunes[upperCount]) {
+		upperCount++
+	}
 
-		implName = strings.ToLower(string(nameRunes[0])) + string(nameRunes[1:])
+	// Lowercase any leading acronym (e.g., "XMPLangAlt" becomes "xmpLangAlt" and "OECF" becomes "oecf"), leaving the
+	// first letter of the following word alone.
 
-		if strings.HasPrefix(implName, "xMP") {
-			implName = "xmp" + string(nameRunes[3:])
-		}
+	if upperCount > 1 && upperCount < len(nameRunes) {
+		upperCount--
+	}
+
+	if upperCount == 0 {
+		upperCount = 1
+	}
 
+	return strings.ToLower(string(nameRunes[:upperCount])) + string(nameRunes[upperCount:])
+}
+
+func getAccessorInfos(accessors map[string]string, decodedAccessors map[string]decodedAccessor) []accessorInfo {
+	var result []accessorInfo
+
+	for name, goType := range accessors {
 		result = append(result, accessorInfo{
-			ImplName: implName,
+			ImplName: getAccessorImplName(name),
 			IsSlice:  strings.HasPrefix(goType, "[]"),
 			Name:     name,
 			Type:     goType,
 		})
 	}
 
+	for name, decoded := range decodedAccessors {
+		result = append(result, accessorInfo{
+			Decoder:          decoded.decoder,
+			DecoderByteOrder: decoded.byteOrder,
+			ImplName:         getAccessorImplName(name),
+			Name:             name,
+			TextDecoder:      decoded.textDecoder,
+			Type:             decoded.goType,
+		})
+	}
+
 	sort.Slice(result, func(i, j int) bool {
 		return strings.Compare(result[i].Name, result[j].Name) < 0
 	})
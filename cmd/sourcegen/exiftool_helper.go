@@ -0,0 +1,151 @@
+package main // import "golang.handcraftedbits.com/ezif/cmd/sourcegen"
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+//
+// Private types
+//
+
+type exifToolConfig struct {
+	Conversions map[string]string `yaml:"conversions"`
+	Groups      map[string]string `yaml:"groups"`
+	Tags        map[string]string `yaml:"tags"`
+}
+
+type exifToolGroupInfo struct {
+	ExifToolGroup string
+	Group         string
+}
+
+type exifToolTagInfo struct {
+	Conversion string
+	Key        string
+	Name       string
+}
+
+type exifToolTemplateContext struct {
+	Groups []exifToolGroupInfo
+	Tags   []exifToolTagInfo
+}
+
+//
+// Private variables
+//
+
+// The conversions supported by the ExifTool exporter, keyed by their name in the sourcegen configuration.
+var exifToolConversions = map[string]string{
+	"exposureTime": "conversionExposureTime",
+	"fNumber":      "conversionFNumber",
+	"focalLength":  "conversionFocalLength",
+}
+
+//
+// Private functions
+//
+
+func generateExifToolSource(metadata families, config exifToolConfig) (string, error) {
+	var buffer bytes.Buffer
+	var templateContext exifToolTemplateContext
+	var err error
+	var formattedSource []byte
+	var groups = make(map[string]string)
+	var templateRoot *template.Template
+
+	for group, exifToolGroup := range config.Groups {
+		groups[group] = exifToolGroup
+	}
+
+	// XMP namespaces are mapped to ExifTool groups by convention, so only exceptions need to be configured.
+
+	for group := range metadata[familyXMP] {
+		if _, ok := groups[familyXMP+"."+group]; !ok {
+			groups[familyXMP+"."+group] = "XMP-" + group
+		}
+	}
+
+	for group, exifToolGroup := range groups {
+		templateContext.Groups = append(templateContext.Groups, exifToolGroupInfo{
+			ExifToolGroup: exifToolGroup,
+			Group:         group,
+		})
+	}
+
+	for familyName, f := range metadata {
+		for groupName, g := range f {
+			var exifToolGroup, ok = groups[familyName+"."+groupName]
+
+			if !ok {
+				continue
+			}
+
+			for tagName := range g {
+				var info = exifToolTagInfo{
+					Key: familyName + "." + groupName + "." + tagName,
+				}
+
+				info.Name = exifToolGroup + ":" + getExifToolTagName(info.Key, tagName, config.Tags)
+
+				if conversion, ok := config.Conversions[info.Key]; ok {
+					if info.Conversion, ok = exifToolConversions[conversion]; !ok {
+						return "", fmt.Errorf("invalid ExifTool conversion '%s' specified for property '%s'", conversion,
+							info.Key)
+					}
+				}
+
+				templateContext.Tags = append(templateContext.Tags, info)
+			}
+		}
+	}
+
+	sort.Slice(templateContext.Groups, func(i, j int) bool {
+		return strings.Compare(templateContext.Groups[i].Group, templateContext.Groups[j].Group) < 0
+	})
+
+	sort.Slice(templateContext.Tags, func(i, j int) bool {
+		return strings.Compare(templateContext.Tags[i].Key, templateContext.Tags[j].Key) < 0
+	})
+
+	templateRoot, err = initTemplate("root", templateExifToolSource)
+
+	if err != nil {
+		return "", err
+	}
+
+	err = templateRoot.Execute(&buffer, &templateContext)
+
+	if err != nil {
+		return "", err
+	}
+
+	// Do a gofmt pass on the generated source.
+
+	formattedSource, err = format.Source(buffer.Bytes())
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(formattedSource), nil
+}
+
+func getExifToolTagName(key, tagName string, tags map[string]string) string {
+	var tagRunes = []rune(tagName)
+
+	if name, ok := tags[key]; ok {
+		return name
+	}
+
+	// ExifTool tag names always start with an uppercase letter, which matters for XMP properties like "dc:subject".
+
+	tagRunes[0] = unicode.ToUpper(tagRunes[0])
+
+	return string(tagRunes)
+}
@@ -20,9 +20,10 @@ type families map[string]family
 type family map[string]group
 
 type functionInfo struct {
-	Family      string
-	FullTagName string
-	Tag         tag
+	AccessorOverride string
+	Family           string
+	FullTagName      string
+	Tag              tag
 }
 
 type group map[string]tag
@@ -33,9 +34,10 @@ type groupConfig struct {
 	Reference   string `yaml:"reference"`
 	Regexp      string `yaml:"regexp"`
 
-	disabledHelpers map[string]bool
-	disabledTests   map[string]bool
-	regexp          *regexp.Regexp
+	accessorOverrides map[string]string
+	disabledHelpers   map[string]bool
+	disabledTests     map[string]bool
+	regexp            *regexp.Regexp
 }
 
 type helperTemplateContext struct {
@@ -69,6 +71,7 @@ type typeIDMapping struct {
 const (
 	familyExif = "Exif"
 	familyIPTC = "Iptc"
+	familyXMP  = "Xmp"
 )
 
 //
@@ -141,7 +144,7 @@ func generateSource(familyName string, f family, packageName string, gc groupCon
 		return "", err
 	}
 
-	functionNames, functionMappings = getFunctionMappings(familyName, f, groupNames)
+	functionNames, functionMappings = getFunctionMappings(familyName, f, groupNames, gc.accessorOverrides)
 
 	err = templateRoot.Execute(&buffer, &helperTemplateContext{
 		DisabledHelpers:    gc.disabledHelpers,
@@ -242,7 +245,8 @@ func getFixedTagName(tagName string) string {
 	return strings.Title(tagName)
 }
 
-func getFunctionMappings(familyName string, f family, groupNames []string) ([]string, map[string]functionInfo) {
+func getFunctionMappings(familyName string, f family, groupNames []string,
+	accessorOverrides map[string]string) ([]string, map[string]functionInfo) {
 	var duplicateTagNames = getDuplicateTagNames(f, groupNames)
 	var functionMappings = make(map[string]functionInfo)
 	var sortedFunctionNames []string
@@ -259,10 +263,13 @@ func getFunctionMappings(familyName string, f family, groupNames []string) ([]st
 
 			sortedFunctionNames = append(sortedFunctionNames, functionName)
 
+			var fullTagName = familyName + "." + groupName + "." + tagName
+
 			functionMappings[functionName] = functionInfo{
-				Family:      familyName,
-				FullTagName: familyName + "." + groupName + "." + tagName,
-				Tag:         f[groupName][tagName],
+				AccessorOverride: accessorOverrides[fullTagName],
+				Family:           familyName,
+				FullTagName:      fullTagName,
+				Tag:              f[groupName][tagName],
 			}
 		}
 	}
@@ -334,6 +341,12 @@ func templateFuncPropertyName(info functionInfo) string {
 }
 
 func templateFuncReturnType(info functionInfo) string {
+	// Overridden accessors already know whether or not they return a slice.
+
+	if info.AccessorOverride != "" {
+		return info.AccessorOverride
+	}
+
 	var count = getAdjustedCount(info)
 	var result = getTypeIDMapping(info.Tag.TypeID).returnType
 
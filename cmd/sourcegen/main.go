@@ -17,9 +17,11 @@ import (
 //
 
 type sourcegenConfig struct {
-	DisabledHelpers []string               `yaml:"disabledHelpers"`
-	DisabledTests   []string               `yaml:"disabledTests"`
-	Groups          map[string]groupConfig `yaml:"groups"`
+	AccessorOverrides map[string]string      `yaml:"accessorOverrides"`
+	DisabledHelpers   []string               `yaml:"disabledHelpers"`
+	DisabledTests     []string               `yaml:"disabledTests"`
+	ExifTool          exifToolConfig         `yaml:"exifTool"`
+	Groups            map[string]groupConfig `yaml:"groups"`
 }
 
 //
@@ -52,6 +54,46 @@ var commandAccessor = &cobra.Command{
 	},
 }
 
+var commandExifTool = &cobra.Command{
+	Use:   "exiftool",
+	Short: "Generate the table used to export metadata in ExifTool format from Exiv2 metadata",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var config *sourcegenConfig
+		var contents []byte
+		var err error
+		var generatedSource string
+		var metadata families
+
+		contents, err = ioutil.ReadFile(flagExifToolMetadata)
+
+		if err != nil {
+			return errors.Wrap(err, "invalid Exiv2 metadata file provided")
+		}
+
+		err = json.Unmarshal(contents, &metadata)
+
+		if err != nil {
+			return errors.Wrap(err, "invalid Exiv2 metadata file provided")
+		}
+
+		config, err = getSourcegenConfig()
+
+		if err != nil {
+			return err
+		}
+
+		generatedSource, err = generateExifToolSource(metadata, config.ExifTool)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(generatedSource)
+
+		return nil
+	},
+}
+
 var commandHelper = &cobra.Command{
 	Use:   "helper",
 	Short: "Generate ezif helper functions and test code from Exiv2 metadata",
@@ -89,6 +131,7 @@ var commandHelper = &cobra.Command{
 
 		gc = config.Groups[flagHelperGroup]
 
+		gc.accessorOverrides = config.AccessorOverrides
 		gc.disabledHelpers = getDisabledItems(config.DisabledHelpers)
 		gc.disabledTests = getDisabledItems(config.DisabledTests)
 		gc.regexp, err = regexp.Compile(gc.Regexp)
@@ -119,12 +162,13 @@ var commandRoot = &cobra.Command{
 }
 
 var (
-	flagAccessorImpl    bool
-	flagAccessorPackage string
-	flagConfig          string
-	flagHelperGroup     string
-	flagHelperMetadata  string
-	flagHelperTest      bool
+	flagAccessorImpl     bool
+	flagAccessorPackage  string
+	flagConfig           string
+	flagExifToolMetadata string
+	flagHelperGroup      string
+	flagHelperMetadata   string
+	flagHelperTest       bool
 )
 
 //
@@ -166,6 +210,9 @@ func main() {
 		"instead of interface code, should be generated")
 	commandAccessor.Flags().StringVarP(&flagAccessorPackage, "package", "p", "", "the package for generated accessor "+
 		"code")
+	commandExifTool.Flags().StringVarP(&flagConfig, "config", "c", "", "path to sourcegen configuration file")
+	commandExifTool.Flags().StringVarP(&flagExifToolMetadata, "metadata", "m", "", "path to JSON-formatted Exiv2 "+
+		"metadata")
 	commandHelper.Flags().StringVarP(&flagConfig, "config", "c", "", "path to sourcegen configuration file")
 	commandHelper.Flags().StringVarP(&flagHelperGroup, "group", "g", "", "name of group to use for code generation")
 	commandHelper.Flags().StringVarP(&flagHelperMetadata, "metadata", "m", "", "path to JSON-formatted Exiv2 metadata")
@@ -173,11 +220,13 @@ func main() {
 		"code, should be generated")
 
 	_ = commandAccessor.MarkFlagRequired("package")
+	_ = commandExifTool.MarkFlagRequired("config")
+	_ = commandExifTool.MarkFlagRequired("metadata")
 	_ = commandHelper.MarkFlagRequired("config")
 	_ = commandHelper.MarkFlagRequired("group")
 	_ = commandHelper.MarkFlagRequired("metadata")
 
-	commandRoot.AddCommand(commandAccessor, commandHelper)
+	commandRoot.AddCommand(commandAccessor, commandExifTool, commandHelper)
 
 	if err := commandRoot.Execute(); err != nil {
 		os.Exit(1)
@@ -21,15 +21,42 @@ import (
 //
 
 {{ range .AccessorInfos }}
+	// Lookup{{ .Name }}Accessor creates an accessor for image metadata properties with {{ .Type }} values, returning an
+	// error instead of an accessor if the value of the property doesn't have the expected type
+	{{- if .Decoder }} or cannot be decoded{{ else if not .IsSlice }} or there is no value{{ end }}.
+	func Lookup{{ .Name }}Accessor (properties metadata.Properties, key string) (helper.{{ .Name }}Accessor, error) {
+		if !properties.HasKey(key) {
+			return nil, nil
+		}
+
+		var accessor = new{{ .Name }}Accessor(properties, key)
+
+		if _, err := accessor.Value(); err != nil {
+			return nil, err
+		}
+
+		return accessor, nil
+	}
+
 	// New{{ .Name }}Accessor creates an accessor for image metadata properties with {{ .Type }} values.
 	func New{{ .Name }}Accessor (properties metadata.Properties, key string) helper.{{ .Name }}Accessor {
 		if !properties.HasKey(key) {
 			return nil
 		}
 
-		return &{{ .ImplName }}AccessorImpl{
-			property: properties.Get(key),
-		}
+		{{- if .Decoder }}
+
+			var accessor = new{{ .Name }}Accessor(properties, key)
+
+			if accessor.err != nil {
+				return nil
+			}
+
+			return accessor
+		{{- else }}
+
+			return new{{ .Name }}Accessor(properties, key)
+		{{- end }}
 	}
 {{ end }}
 
@@ -41,18 +68,99 @@ import (
 	// helper.{{ .Name }}Accessor implementation
 	type {{ .ImplName }}AccessorImpl struct {
 		property metadata.Property
+		{{- if .Decoder }}
+			err      error
+			value    {{ .Type }}
+		{{- end }}
+	}
+
+	func (accessor *{{ .ImplName }}AccessorImpl) Interpreted () string {
+		return accessor.property.InterpretedValue()
 	}
 
 	func (accessor *{{ .ImplName }}AccessorImpl) Raw () {{ .Type }} {
-		{{- if .IsSlice }}
-			return accessor.property.Value().({{ .Type }})
-		{{ else }}
-			return accessor.property.Value().([]{{ .Type }})[0]
-		{{ end -}}
+		var value, _ = accessor.Value()
+
+		return value
 	}
 
-	func (accessor *{{ .ImplName }}AccessorImpl) Interpreted () string {
-		return accessor.property.InterpretedValue()
+	func (accessor *{{ .ImplName }}AccessorImpl) Value () ({{ .Type }}, error) {
+		{{- if .Decoder }}
+			return accessor.value, accessor.err
+		{{- else if .IsSlice }}
+			var value, ok = accessor.property.Value().({{ .Type }})
+
+			if !ok {
+				return nil, newTypeMismatchError(accessor.property, "{{ .Type }}")
+			}
+
+			return value, nil
+		{{- else }}
+			var value {{ .Type }}
+			var values, ok = accessor.property.Value().([]{{ .Type }})
+
+			if !ok {
+				return value, newTypeMismatchError(accessor.property, "[]{{ .Type }}")
+			}
+
+			if len(values) == 0 {
+				return value, newNoValueError(accessor.property)
+			}
+
+			return values[0], nil
+		{{- end }}
+	}
+{{ end }}
+
+//
+// Private functions
+//
+
+{{ range .AccessorInfos }}
+	func new{{ .Name }}Accessor (properties metadata.Properties, key string) *{{ .ImplName }}AccessorImpl {
+		{{- if .Decoder }}
+			var accessor = &{{ .ImplName }}AccessorImpl{
+				property: properties.Get(key),
+			}
+
+			switch data := accessor.property.Value().(type) {
+			case []byte:
+				{{ if .DecoderByteOrder -}}
+					var value, err = {{ .Decoder }}(data, properties.ByteOrder())
+				{{- else -}}
+					var value, err = {{ .Decoder }}(data)
+				{{- end }}
+
+				if err != nil {
+					accessor.err = newDecodeError(accessor.property, err)
+
+					return accessor
+				}
+
+				accessor.value = value
+			{{- if .TextDecoder }}
+
+			case []string:
+				if len(data) == 0 {
+					accessor.err = newNoValueError(accessor.property)
+
+					return accessor
+				}
+
+				accessor.value = {{ .TextDecoder }}(data[0])
+			{{- end }}
+
+			default:
+				accessor.err = newTypeMismatchError(accessor.property, "[]byte
+				{{- if .TextDecoder }} or []string{{ end }}")
+			}
+
+			return accessor
+		{{- else }}
+			return &{{ .ImplName }}AccessorImpl{
+				property: properties.Get(key),
+			}
+		{{- end }}
 	}
 {{ end }}
 `
@@ -82,12 +190,46 @@ type Accessor interface {
 type {{ .Name }}Accessor interface {
 	Accessor
 
-	// Raw accesses the raw {{ .Type }} value of an image metadata property.
+	// Raw accesses the {{ if .Decoder }}decoded{{ else }}raw{{ end }} {{ .Type }} value of an image metadata
+	// property, or the zero value if it cannot be accessed (see Value).
 	Raw () {{ .Type }}
+
+	// Value accesses the {{ if .Decoder }}decoded{{ else }}raw{{ end }} {{ .Type }} value of an image metadata
+	// property, returning a *ValueError if the value doesn't have the expected type
+	{{- if .Decoder }} or cannot be decoded{{ else if not .IsSlice }} or there is no value{{ end }}.
+	Value () ({{ .Type }}, error)
 }
 {{ end }}
 `
 
+var templateExifToolSource = `// Code generated by ezif.  DO NOT EDIT.
+
+package exiftool // import "golang.handcraftedbits.com/ezif/helper/exiftool"
+
+//
+// Private variables
+//
+
+// groups maps Exiv2 groups to ExifTool groups.
+var groups = map[string]string{
+	{{- range .Groups }}
+		"{{ .Group }}": "{{ .ExifToolGroup }}",
+	{{- end }}
+}
+
+// tags maps Exiv2 property keys to ExifTool tags.
+var tags = map[string]tag{
+	{{- range .Tags }}
+		"{{ .Key }}": {
+			name: "{{ .Name }}",
+			{{- if .Conversion }}
+				conversion: {{ .Conversion }},
+			{{- end }}
+		},
+	{{- end }}
+}
+`
+
 var templateGroupSource = `// Code generated by ezif.  DO NOT EDIT.
 
 // Package {{ .PackageName | LastPackage }} defines helper functions for accessing {{ .PackageDescription }}.
@@ -118,8 +260,18 @@ import (
 		// See the Exiv2 documentation regarding property "{{ $functionInfo.FullTagName }}" for more information.
 		//
 		// Note that this function will return nil if the image metadata does not contain this property.
+		{{- if $functionInfo.AccessorOverride }}
+			// The function will also return nil if the value of the property cannot be decoded.
+		{{- end }}
 		func {{ . }} (collection metadata.Collection) helper.{{ ReturnType $functionInfo }}Accessor {
 			return internal.New{{ ReturnType $functionInfo }}Accessor(collection.{{ PropertyName $functionInfo }}(), ` +
+	`"{{ $functionInfo.FullTagName }}")
+		}
+
+		// Lookup{{ . }} is like {{ . }}, but returns a *helper.ValueError instead of an accessor if the value of the ` +
+	`property cannot be accessed.
+		func Lookup{{ . }} (collection metadata.Collection) (helper.{{ ReturnType $functionInfo }}Accessor, error) {
+			return internal.Lookup{{ ReturnType $functionInfo }}Accessor(collection.{{ PropertyName $functionInfo }}(), ` +
 	`"{{ $functionInfo.FullTagName }}")
 		}
 	{{ end -}}
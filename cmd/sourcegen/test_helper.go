@@ -1,5 +1,18 @@
 package main // import "golang.handcraftedbits.com/ezif/cmd/sourcegen"
 
+import "golang.handcraftedbits.com/ezif/types"
+
+//
+// Private variables
+//
+
+// Overridden accessors decode structured values, so the generated tests (which write random values) can only be used
+// for those that decode values the external Exiv2 command can write from a string, and only when the property has the
+// type the accessor expects.
+var testableAccessorOverrides = map[string]types.ID{
+	"Comment": types.IDComment,
+}
+
 //
 // Private functions
 //
@@ -13,5 +26,13 @@ func templateFuncIsSlice(info functionInfo) bool {
 }
 
 func templateFuncIsTestEnabled(info functionInfo, disabledTests map[string]bool) bool {
+	if info.AccessorOverride != "" {
+		var typeID, ok = testableAccessorOverrides[info.AccessorOverride]
+
+		if !ok || typeID != info.Tag.TypeID {
+			return false
+		}
+	}
+
 	return !disabledTests[info.FullTagName]
 }
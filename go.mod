@@ -1,13 +1,17 @@
 module golang.handcraftedbits.com/ezif
 
-go 1.12
+go 1.23
 
 require (
-	github.com/coreos/etcd v3.3.10+incompatible
-	github.com/mattn/go-pointer v0.0.0-20180825124634-49522c3f3791
 	github.com/pkg/errors v0.8.1
-	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/cobra v0.0.5
 	github.com/stretchr/testify v1.4.0
 	gopkg.in/yaml.v2 v2.2.2
 )
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/inconshreveable/mousetrap v1.0.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/spf13/pflag v1.0.3 // indirect
+)
@@ -0,0 +1,42 @@
+package helper // import "golang.handcraftedbits.com/ezif/helper"
+
+import (
+	"errors"
+	"fmt"
+)
+
+//
+// Public types
+//
+
+// ValueError is returned by accessors when the value of an image metadata property can't be accessed.  Errors caused
+// by an unexpected value type or a missing value are matched by ErrTypeMismatch or ErrNoValue when using errors.Is.
+type ValueError struct {
+	// Key is the key of the metadata property.
+	Key string
+
+	// Err describes why the value couldn't be accessed.
+	Err error
+}
+
+func (err *ValueError) Error() string {
+	return fmt.Sprintf("could not access value of metadata property '%s': %v", err.Key, err.Err)
+}
+
+// Unwrap returns the error describing why the value couldn't be accessed.
+func (err *ValueError) Unwrap() error {
+	return err.Err
+}
+
+//
+// Public variables
+//
+
+var (
+	// ErrNoValue indicates that an image metadata property has no value.
+	ErrNoValue = errors.New("property has no value")
+
+	// ErrTypeMismatch indicates that the value of an image metadata property doesn't have the type expected by its
+	// accessor, which happens when an image stores a property using a type other than the one documented by Exiv2.
+	ErrTypeMismatch = errors.New("unexpected property value type")
+)
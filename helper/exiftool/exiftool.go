@@ -0,0 +1,402 @@
+// Package exiftool renders image metadata the way ExifTool does when run with "exiftool -j -G1", so that ezif can be
+// swapped in for ExifTool without rewriting tools that consume its output.
+//
+// Exiv2 property keys are mapped to ExifTool tag names using a table generated from Exiv2 metadata (e.g.,
+// "Exif.Photo.FNumber" becomes "ExifIFD:FNumber"), and values are formatted following ExifTool conventions: numbers
+// are exported as JSON numbers, multiple numeric values are joined by spaces, list values (e.g., IPTC keywords) are
+// exported as arrays, dates use colons as separators and binary values are replaced by a placeholder.  Note that
+// ExifTool applies tag-specific conversions to many values (e.g., exporting an orientation of 1 as "Horizontal
+// (normal)"), and only a handful of these are replicated.
+package exiftool // import "golang.handcraftedbits.com/ezif/helper/exiftool"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.handcraftedbits.com/ezif/metadata"
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+//
+// Public types
+//
+
+// File is the metadata of a single file to be exported.
+type File struct {
+	// Collection is the metadata of the file.
+	Collection metadata.Collection
+
+	// SourceFile is the name of the file, which is exported as the SourceFile tag.
+	SourceFile string
+}
+
+//
+// Public functions
+//
+
+// Marshal exports the metadata of the given files as a JSON array containing one object per file, mapping ExifTool tag
+// names to values.  Like ExifTool, tags are exported in the order in which they were found in the image (see
+// metadata.Properties.AllInFileOrder()), starting with Exif, followed by IPTC and XMP.
+func Marshal(files ...File) ([]byte, error) {
+	var objects = make([]object, len(files))
+
+	for i, file := range files {
+		objects[i] = newObject(file)
+	}
+
+	return json.Marshal(objects)
+}
+
+// TagName returns the ExifTool tag name ("Group:TagName") of the metadata property with the given Exiv2 key.  Keys
+// that aren't known to ExifTool are given a name built from their Exiv2 group and tag names.
+func TagName(key string) string {
+	return getTag(key).name
+}
+
+//
+// Private types
+//
+
+type conversion int
+
+// object is a JSON object whose members are marshaled in the order in which they were added, just like ExifTool
+// exports tags in the order in which they were found.
+type object []objectMember
+
+func (obj object) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+
+	buffer.WriteByte('{')
+
+	for i, member := range obj {
+		var name, value []byte
+		var err error
+
+		if name, err = json.Marshal(member.name); err != nil {
+			return nil, err
+		}
+
+		if value, err = json.Marshal(member.value); err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+
+		buffer.Write(name)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+
+	buffer.WriteByte('}')
+
+	return buffer.Bytes(), nil
+}
+
+func (obj *object) add(names map[string]bool, name string, value interface{}) {
+	// ExifTool only exports the first of multiple tags with the same name.
+
+	if value == nil || names[name] {
+		return
+	}
+
+	names[name] = true
+
+	*obj = append(*obj, objectMember{
+		name:  name,
+		value: value,
+	})
+}
+
+type objectMember struct {
+	name  string
+	value interface{}
+}
+
+type tag struct {
+	conversion conversion
+	name       string
+}
+
+//
+// Private constants
+//
+
+const (
+	conversionNone conversion = iota
+	conversionExposureTime
+	conversionFNumber
+	conversionFocalLength
+)
+
+const tagSourceFile = "SourceFile"
+
+//
+// Private variables
+//
+
+// The same expression ExifTool uses to decide whether a value should be exported as a JSON number.
+var numberRegexp = regexp.MustCompile(`^-?(\d|[1-9]\d{1,14})(\.\d{1,16})?([eE][-+]?\d{1,3})?$`)
+
+//
+// Private functions
+//
+
+func applyConversion(conversion conversion, value float64) string {
+	switch conversion {
+	case conversionExposureTime:
+		if value > 0 && value < 0.25001 {
+			return fmt.Sprintf("1/%d", int(0.5+(1/value)))
+		}
+
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", value), ".0")
+
+	case conversionFNumber:
+		return fmt.Sprintf("%.1f", value)
+
+	case conversionFocalLength:
+		return fmt.Sprintf("%.1f mm", value)
+	}
+
+	return strconv.FormatFloat(value, 'g', 10, 64)
+}
+
+func formatBytes(typeID types.ID, value []byte) interface{} {
+	if typeID == types.IDUnsignedByte {
+		var strs = make([]string, len(value))
+
+		for i, b := range value {
+			strs[i] = strconv.Itoa(int(b))
+		}
+
+		return joinValues(strs)
+	}
+
+	// Undefined values that are really just text (e.g., Exif.Photo.ExifVersion) are exported as such.
+
+	var text = strings.TrimRight(string(value), "\x00")
+
+	for _, r := range text {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			return fmt.Sprintf("(Binary data %d bytes, use -b option to extract)", len(value))
+		}
+	}
+
+	return toJSONValue(text)
+}
+
+func formatIPTCTime(value types.IPTCTime) string {
+	var _, offset = time.Date(0, time.January, 1, 0, 0, 0, 0, value.Timezone()).Zone()
+	var sign = "+"
+
+	if offset < 0 {
+		offset = -offset
+		sign = "-"
+	}
+
+	return fmt.Sprintf("%02d:%02d:%02d%s%02d:%02d", value.Hour(), value.Minute(), value.Second(), sign, offset/3600,
+		(offset%3600)/60)
+}
+
+func formatValue(property metadata.Property, conversion conversion) interface{} {
+	var strs []string
+
+	switch value := property.Value().(type) {
+	case []byte:
+		if len(value) == 0 {
+			return nil
+		}
+
+		return formatBytes(property.TypeID(), value)
+
+	case []string:
+		return listValues(value)
+
+	case []types.IPTCDate:
+		for _, date := range value {
+			strs = append(strs, fmt.Sprintf("%04d:%02d:%02d", date.Year(), date.Month(), date.Day()))
+		}
+
+		return listValues(strs)
+
+	case []types.IPTCTime:
+		for _, iptcTime := range value {
+			strs = append(strs, formatIPTCTime(iptcTime))
+		}
+
+		return listValues(strs)
+
+	case []*big.Rat:
+		for _, rat := range value {
+			var number, _ = rat.Float64()
+
+			strs = append(strs, applyConversion(conversion, number))
+		}
+
+	case []float32:
+		for _, number := range value {
+			strs = append(strs, strconv.FormatFloat(float64(number), 'g', -1, 32))
+		}
+
+	case []float64:
+		for _, number := range value {
+			if math.IsNaN(number) || math.IsInf(number, 0) {
+				strs = append(strs, strconv.FormatFloat(number, 'g', -1, 64))
+			} else {
+				strs = append(strs, applyConversion(conversion, number))
+			}
+		}
+
+	case []int8:
+		for _, number := range value {
+			strs = append(strs, strconv.FormatInt(int64(number), 10))
+		}
+
+	case []int16:
+		for _, number := range value {
+			strs = append(strs, strconv.FormatInt(int64(number), 10))
+		}
+
+	case []int32:
+		for _, number := range value {
+			strs = append(strs, strconv.FormatInt(int64(number), 10))
+		}
+
+	case []uint16:
+		for _, number := range value {
+			strs = append(strs, strconv.FormatUint(uint64(number), 10))
+		}
+
+	case []uint32:
+		for _, number := range value {
+			strs = append(strs, strconv.FormatUint(uint64(number), 10))
+		}
+	}
+
+	// Numeric values aren't lists as far as ExifTool is concerned, so they're joined into a single value.
+
+	return joinValues(strs)
+}
+
+func getTag(key string) tag {
+	var parts []string
+	var tagRunes []rune
+
+	if result, ok := tags[key]; ok {
+		return result
+	}
+
+	parts = strings.SplitN(key, ".", 3)
+
+	if len(parts) != 3 || parts[2] == "" {
+		return tag{name: key}
+	}
+
+	if group, ok := groups[parts[0]+"."+parts[1]]; ok {
+		parts[1] = group
+	} else if parts[0] == string(metadata.FamilyXMP) {
+		parts[1] = "XMP-" + parts[1]
+	}
+
+	tagRunes = []rune(parts[2])
+	tagRunes[0] = unicode.ToUpper(tagRunes[0])
+
+	return tag{name: parts[1] + ":" + string(tagRunes)}
+}
+
+func joinValues(strs []string) interface{} {
+	if len(strs) == 0 {
+		return nil
+	}
+
+	return toJSONValue(strings.Join(strs, " "))
+}
+
+func listValues(strs []string) interface{} {
+	var values []interface{}
+
+	switch len(strs) {
+	case 0:
+		return nil
+
+	case 1:
+		return toJSONValue(strs[0])
+	}
+
+	values = make([]interface{}, len(strs))
+
+	for i, str := range strs {
+		values[i] = toJSONValue(str)
+	}
+
+	return values
+}
+
+func newObject(file File) object {
+	var names = map[string]bool{}
+	var result object
+
+	result.add(names, tagSourceFile, file.SourceFile)
+
+	if file.Collection == nil {
+		return result
+	}
+
+	for _, properties := range []metadata.Properties{file.Collection.Exif(), file.Collection.IPTC(),
+		file.Collection.XMP()} {
+		for key, property := range properties.AllInFileOrder() {
+			var propertyTag = getTag(key)
+
+			// ExifTool exports the default language of a language alternative using the tag name alone, and other
+			// languages by appending the language to the tag name (e.g., "XMP-dc:Title-de").
+
+			if langAlts, ok := property.Value().([]map[string]string); ok {
+				var languages []string
+
+				if len(langAlts) == 0 {
+					continue
+				}
+
+				for language := range langAlts[0] {
+					languages = append(languages, language)
+				}
+
+				sort.Strings(languages)
+
+				if value, ok := langAlts[0]["x-default"]; ok {
+					result.add(names, propertyTag.name, toJSONValue(value))
+				}
+
+				for _, language := range languages {
+					if language != "x-default" {
+						result.add(names, propertyTag.name+"-"+language, toJSONValue(langAlts[0][language]))
+					}
+				}
+
+				continue
+			}
+
+			result.add(names, propertyTag.name, formatValue(property, propertyTag.conversion))
+		}
+	}
+
+	return result
+}
+
+func toJSONValue(str string) interface{} {
+	if numberRegexp.MatchString(str) {
+		return json.Number(str)
+	}
+
+	return str
+}
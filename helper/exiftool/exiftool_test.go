@@ -0,0 +1,87 @@
+package exiftool // import "golang.handcraftedbits.com/ezif/helper/exiftool"
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.handcraftedbits.com/ezif/metadata"
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+//
+// Public functions
+//
+
+func TestFormat(t *testing.T) {
+	require.Equal(t, "1/200", applyConversion(conversionExposureTime, 0.005))
+	require.Equal(t, "2", applyConversion(conversionExposureTime, 2))
+	require.Equal(t, "2.8", applyConversion(conversionFNumber, 2.8))
+	require.Equal(t, "50.0 mm", applyConversion(conversionFocalLength, 50))
+	require.Equal(t, "0.3333333333", applyConversion(conversionNone, 1.0/3))
+
+	require.Equal(t, "0 1 255", formatBytes(types.IDUnsignedByte, []byte{0, 1, 255}))
+	require.Equal(t, "0230", formatBytes(types.IDUndefined, []byte("0230")))
+	require.Equal(t, "ASCII", formatBytes(types.IDUndefined, []byte("ASCII\x00\x00\x00")))
+	require.Equal(t, "(Binary data 3 bytes, use -b option to extract)", formatBytes(types.IDUndefined,
+		[]byte{0, 1, 255}))
+
+	require.Equal(t, "15:09:26+05:30", formatIPTCTime(types.NewIPTCTime(15, 9, 26, 5, 30)))
+	require.Equal(t, "15:09:26-05:00", formatIPTCTime(types.NewIPTCTime(15, 9, 26, -5, 0)))
+}
+
+func TestMarshal(t *testing.T) {
+	var collection, err = metadata.NewBuilder().
+		Set("Exif.Image.Make", "Canon").
+		Set("Exif.Image.BitsPerSample", []uint16{8, 8, 8}).
+		Set("Exif.Photo.FNumber", big.NewRat(28, 10)).
+		Set("Iptc.Application2.DateCreated", types.NewIPTCDate(2020, time.March, 14)).
+		Set("Iptc.Application2.Keywords", []string{"one", "2"}).
+		Set("Xmp.dc.subject", []string{"one"}).
+		Set("Xmp.dc.title", map[string]string{"x-default": "Title", "de": "Titel"}).
+		Build()
+	var data []byte
+
+	require.NoError(t, err)
+
+	data, err = Marshal(File{Collection: collection, SourceFile: "image.jpg"}, File{SourceFile: "empty.jpg"})
+
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{
+			"SourceFile": "image.jpg",
+			"IFD0:BitsPerSample": "8 8 8",
+			"IFD0:Make": "Canon",
+			"ExifIFD:FNumber": 2.8,
+			"IPTC:DateCreated": "2020:03:14",
+			"IPTC:Keywords": ["one", 2],
+			"XMP-dc:Subject": "one",
+			"XMP-dc:Title": "Title",
+			"XMP-dc:Title-de": "Titel"
+		},
+		{
+			"SourceFile": "empty.jpg"
+		}
+	]`, string(data))
+
+	// Tags are exported in the order in which they were found, Exif first.
+
+	require.Regexp(t, `^\[\{"SourceFile":"image.jpg","IFD0:Make":"Canon","IFD0:BitsPerSample":.*`+
+		`"XMP-dc:Title-de":"Titel"\}`, string(data))
+}
+
+func TestTagName(t *testing.T) {
+	for key, expected := range map[string]string{
+		"Exif.Image.Make":            "IFD0:Make",
+		"Exif.NoSuchGroup.tag":       "NoSuchGroup:Tag",
+		"Exif.Photo.FNumber":         "ExifIFD:FNumber",
+		"Iptc.Application2.Keywords": "IPTC:Keywords",
+		"Xmp.dc.title":               "XMP-dc:Title",
+		"Xmp.newNamespace.property":  "XMP-newNamespace:Property",
+		"invalid":                    "invalid",
+	} {
+		require.Equal(t, expected, TagName(key), key)
+	}
+}
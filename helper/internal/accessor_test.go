@@ -0,0 +1,113 @@
+package internal // import "golang.handcraftedbits.com/ezif/helper/internal"
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.handcraftedbits.com/ezif/helper"
+	"golang.handcraftedbits.com/ezif/metadata"
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+//
+// Public functions
+//
+
+func TestAccessorValueErrors(t *testing.T) {
+	var accessor helper.UnsignedShortAccessor
+	var collection metadata.Collection
+	var err error
+	var tests = []struct {
+		err      error
+		name     string
+		sliceErr error
+		typeID   types.ID
+		value    interface{}
+	}{
+		{
+			name:   "Value",
+			typeID: types.IDUnsignedShort,
+			value:  []uint16{8, 16},
+		},
+		{
+			err:    helper.ErrNoValue,
+			name:   "NoValue",
+			typeID: types.IDUnsignedShort,
+			value:  []uint16{},
+		},
+		{
+			err:      helper.ErrTypeMismatch,
+			name:     "TypeMismatch",
+			sliceErr: helper.ErrTypeMismatch,
+			typeID:   types.IDAsciiString,
+			value:    "8",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var accessor helper.UnsignedShortAccessor
+			var collection, err = metadata.NewBuilder().
+				SetWithType("Exif.Image.BitsPerSample", test.typeID, test.value).
+				Build()
+			var sliceAccessor helper.UnsignedShortSliceAccessor
+			var value uint16
+			var valueErr *helper.ValueError
+
+			require.NoError(t, err)
+
+			accessor, err = LookupUnsignedShortAccessor(collection.Exif(), "Exif.Image.BitsPerSample")
+
+			if test.err == nil {
+				require.NoError(t, err)
+				require.Equal(t, uint16(8), accessor.Raw())
+
+				value, err = accessor.Value()
+
+				require.NoError(t, err)
+				require.Equal(t, uint16(8), value)
+			} else {
+				require.True(t, errors.Is(err, test.err), "unexpected error: %v", err)
+				require.True(t, errors.As(err, &valueErr))
+				require.Equal(t, "Exif.Image.BitsPerSample", valueErr.Key)
+				require.Nil(t, accessor)
+
+				// Accessors created without checking the value report the same error, and never panic.
+
+				accessor = NewUnsignedShortAccessor(collection.Exif(), "Exif.Image.BitsPerSample")
+
+				require.Zero(t, accessor.Raw())
+
+				_, err = accessor.Value()
+
+				require.True(t, errors.Is(err, test.err), "unexpected error: %v", err)
+			}
+
+			// An empty slice is a perfectly good slice value.
+
+			sliceAccessor, err = LookupUnsignedShortSliceAccessor(collection.Exif(), "Exif.Image.BitsPerSample")
+
+			if test.sliceErr == nil {
+				require.NoError(t, err)
+				require.Equal(t, test.value, sliceAccessor.Raw())
+			} else {
+				require.True(t, errors.Is(err, test.sliceErr), "unexpected error: %v", err)
+				require.Nil(t, NewUnsignedShortSliceAccessor(collection.Exif(), "Exif.Image.BitsPerSample").Raw())
+			}
+		})
+	}
+
+	// Missing properties have no accessor, which isn't an error.
+
+	collection, err = metadata.NewBuilder().Build()
+
+	require.NoError(t, err)
+
+	accessor, err = LookupUnsignedShortAccessor(collection.Exif(), "Exif.Image.BitsPerSample")
+
+	require.NoError(t, err)
+	require.Nil(t, accessor)
+	require.Nil(t, NewUnsignedShortAccessor(collection.Exif(), "Exif.Image.BitsPerSample"))
+}
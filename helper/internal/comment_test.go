@@ -0,0 +1,141 @@
+package internal // import "golang.handcraftedbits.com/ezif/helper/internal"
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.handcraftedbits.com/ezif/helper"
+	"golang.handcraftedbits.com/ezif/internal/testimage"
+	"golang.handcraftedbits.com/ezif/metadata"
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+//
+// Public functions
+//
+
+func TestCommentAccessorFromImage(t *testing.T) {
+	var tests = []struct {
+		byteOrder binary.ByteOrder
+		charset   types.CommentCharset
+		comment   []byte
+		name      string
+		text      string
+	}{
+		{
+			byteOrder: binary.LittleEndian,
+			charset:   types.CommentCharsetASCII,
+			comment:   []byte("ASCII\x00\x00\x00Hello, world\x00"),
+			name:      "ASCII",
+			text:      "Hello, world",
+		},
+		{
+			byteOrder: binary.BigEndian,
+			charset:   types.CommentCharsetUnicode,
+			comment:   []byte("UNICODE\x00\x00H\x00\xE9\x00l\x00l\x00o"),
+			name:      "Unicode",
+			text:      "Héllo",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var accessor helper.CommentAccessor
+			var collection metadata.Collection
+			var err error
+			var image = &testimage.Image{
+				ByteOrder: test.byteOrder,
+				ExifIFD: []testimage.Entry{
+					testimage.Undefined(tagUserComment, test.comment),
+				},
+			}
+			var value types.Comment
+
+			collection, err = metadata.FromBytes(image.JPEG())
+
+			require.NoError(t, err)
+
+			accessor, err = LookupCommentAccessor(collection.Exif(), "Exif.Photo.UserComment")
+
+			require.NoError(t, err)
+			require.NotNil(t, accessor)
+
+			value, err = accessor.Value()
+
+			require.NoError(t, err)
+			require.Equal(t, test.charset, value.Charset())
+			require.Equal(t, test.text, value.Text())
+		})
+	}
+}
+
+func TestCommentAccessorValueTypes(t *testing.T) {
+	var tests = []struct {
+		charset types.CommentCharset
+		err     error
+		name    string
+		text    string
+		typeID  types.ID
+		value   interface{}
+	}{
+		{
+			charset: types.CommentCharsetUnicode,
+			name:    "Comment",
+			text:    "Héllo",
+			typeID:  types.IDComment,
+			value:   "Héllo",
+		},
+		{
+			charset: types.CommentCharsetASCII,
+			name:    "Undefined",
+			text:    "Hello",
+			typeID:  types.IDUndefined,
+			value:   []byte("ASCII\x00\x00\x00Hello"),
+		},
+		{
+			err:    helper.ErrTypeMismatch,
+			name:   "UnsignedShort",
+			typeID: types.IDUnsignedShort,
+			value:  uint16(1),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var accessor helper.CommentAccessor
+			var collection, err = metadata.NewBuilder().
+				SetWithType("Exif.Photo.UserComment", test.typeID, test.value).
+				Build()
+			var value types.Comment
+
+			require.NoError(t, err)
+
+			accessor, err = LookupCommentAccessor(collection.Exif(), "Exif.Photo.UserComment")
+
+			if test.err != nil {
+				require.True(t, errors.Is(err, test.err))
+				require.Nil(t, accessor)
+				require.Nil(t, NewCommentAccessor(collection.Exif(), "Exif.Photo.UserComment"))
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			value, err = accessor.Value()
+
+			require.NoError(t, err)
+			require.Equal(t, test.charset, value.Charset())
+			require.Equal(t, test.text, value.Text())
+		})
+	}
+}
+
+//
+// Private constants
+//
+
+const tagUserComment = 0x9286
@@ -0,0 +1,37 @@
+package internal // import "golang.handcraftedbits.com/ezif/helper/internal"
+
+import (
+	"fmt"
+
+	"golang.handcraftedbits.com/ezif/helper"
+	"golang.handcraftedbits.com/ezif/metadata"
+)
+
+//
+// Private functions
+//
+
+func getPropertyKey(property metadata.Property) string {
+	return string(property.Family()) + "." + property.GroupName() + "." + property.TagName()
+}
+
+func newDecodeError(property metadata.Property, err error) error {
+	return &helper.ValueError{
+		Err: err,
+		Key: getPropertyKey(property),
+	}
+}
+
+func newNoValueError(property metadata.Property) error {
+	return &helper.ValueError{
+		Err: helper.ErrNoValue,
+		Key: getPropertyKey(property),
+	}
+}
+
+func newTypeMismatchError(property metadata.Property, expectedType string) error {
+	return &helper.ValueError{
+		Err: fmt.Errorf("%w: expected %s, found %T", helper.ErrTypeMismatch, expectedType, property.Value()),
+		Key: getPropertyKey(property),
+	}
+}
@@ -2,14 +2,14 @@ package testing // import "golang.handcraftedbits.com/ezif/internal/testing"
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"math/big"
 	"os"
 	"os/exec"
 
-	log "github.com/sirupsen/logrus"
-
 	"golang.handcraftedbits.com/ezif/internal"
 )
 
@@ -67,11 +67,11 @@ func (exiv2 *externalExiv2Impl) execute() (error, string, string) {
 	command.Stderr = &stdErr
 	command.Stdout = &stdOut
 
-	if internal.Log.IsLevelEnabled(log.DebugLevel) {
-		internal.Log.WithFields(log.Fields{
-			"args": args,
-			"path": command.Path,
-		}).Debug("invoking external Exiv2 command")
+	if internal.Log.Enabled(context.Background(), slog.LevelDebug) {
+		internal.Log.Debug("invoking external Exiv2 command",
+			"args", args,
+			"path", command.Path,
+		)
 	}
 
 	if err := command.Run(); err != nil {
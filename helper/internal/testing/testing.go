@@ -132,6 +132,15 @@ func expectEqualValues(t *testing.T, typeID types.ID, expected []interface{}, ac
 
 	for i := 0; i < len(expected); i++ {
 		switch typeID {
+		case types.IDComment:
+			// Comments are decoded by the Comment accessor, so only the text can be compared to the value we wrote.
+
+			var comment, ok = actual[i].(types.Comment)
+
+			require.True(t, ok, fmt.Sprintf("value at index %d is not a comment", i))
+			require.Equal(t, expected[i], comment.Text(), fmt.Sprintf("value at index %d does not equal expected "+
+				"value", i))
+
 		case types.IDSignedRational, types.IDUnsignedRational:
 			// Unfortunately we can't use require.Equal() directly on the two lists because big.Rat values can't
 			// necessarily be compared on a field-by-field basis -- there seems to be some sort of constant value
@@ -197,6 +206,18 @@ func getRawValueFromAccessor(accessor helper.Accessor) []interface{} {
 	return []interface{}{value.Interface()}
 }
 
+func getValueErrorFromAccessor(accessor helper.Accessor) error {
+	var method = getMethodFromAccessor(accessor, "Value")
+
+	if method == reflect.ValueOf(nil) {
+		return nil
+	}
+
+	var err, _ = method.Call([]reflect.Value{})[1].Interface().(error)
+
+	return err
+}
+
 func makeSlice(typeID types.ID, length int, valueFunc func(types.ID) interface{}) []interface{} {
 	var result = make([]interface{}, length)
 
@@ -299,6 +320,7 @@ func testGetValueFromHelper(t *testing.T, exiv2 *externalExiv2Impl, context *Gen
 	result = getRawValueFromAccessor(context.AccessorFunc(collection))
 
 	require.NotNil(t, result, "couldn't find metadata property with name '%s' in test image", context.Name)
+	require.Nil(t, getValueErrorFromAccessor(context.AccessorFunc(collection)))
 
 	expectEqualValues(t, context.TypeID, valuesToSet, result)
 }
@@ -1,53 +1,49 @@
 package internal // import "golang.handcraftedbits.com/ezif/internal"
 
 import (
+	"io"
+	"log/slog"
 	"os"
 	"strings"
-
-	"github.com/sirupsen/logrus"
 )
 
 //
 // Public variables
 //
 
-var Log *logrus.Logger
+// Log is the default logger, used whenever a logger hasn't been provided by the caller.  It is configured using the
+// EZIF_LOG_FORMAT ("json" or "text") and EZIF_LOG_LEVEL ("debug", "info", "warn" or "error") environment variables.
+var Log *slog.Logger
 
 //
 // Private functions
 //
 
 func init() {
-	var formatter logrus.Formatter
-	var level logrus.Level
-
-	formatter, level = newLogConfig(os.Getenv("EZIF_LOG_FORMAT"), os.Getenv("EZIF_LOG_LEVEL"))
+	Log = newLogger(os.Stderr, os.Getenv("EZIF_LOG_FORMAT"), os.Getenv("EZIF_LOG_LEVEL"))
+}
 
-	Log = logrus.New()
+func newLogger(writer io.Writer, format, level string) *slog.Logger {
+	var handlerOptions = &slog.HandlerOptions{}
 
-	Log.Formatter = formatter
-	Log.Level = level
-}
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug", "trace":
+		handlerOptions.Level = slog.LevelDebug
 
-func newLogConfig(formatter, level string) (logrus.Formatter, logrus.Level) {
-	var err error
-	var parsedFormatter logrus.Formatter
-	var parsedLevel logrus.Level
+	case "warn", "warning":
+		handlerOptions.Level = slog.LevelWarn
 
-	parsedLevel, err = logrus.ParseLevel(level)
+	case "error", "fatal", "panic":
+		handlerOptions.Level = slog.LevelError
 
-	if err != nil {
-		parsedLevel = logrus.InfoLevel
+	default:
+		handlerOptions.Level = slog.LevelInfo
 	}
 
-	switch strings.ToLower(strings.TrimSpace(formatter)) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
 	case "json":
-		parsedFormatter = &logrus.JSONFormatter{}
+		return slog.New(slog.NewJSONHandler(writer, handlerOptions))
 	default:
-		parsedFormatter = &logrus.TextFormatter{
-			EnvironmentOverrideColors: true,
-		}
+		return slog.New(slog.NewTextHandler(writer, handlerOptions))
 	}
-
-	return parsedFormatter, parsedLevel
 }
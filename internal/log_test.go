@@ -0,0 +1,49 @@
+package internal // import "golang.handcraftedbits.com/ezif/internal"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+//
+// Public functions
+//
+
+func TestNewLogger(t *testing.T) {
+	var output bytes.Buffer
+	var record map[string]interface{}
+
+	for level, expected := range map[string]slog.Level{
+		"":        slog.LevelInfo,
+		"DEBUG":   slog.LevelDebug,
+		"error":   slog.LevelError,
+		"fatal":   slog.LevelError,
+		"info":    slog.LevelInfo,
+		"trace":   slog.LevelDebug,
+		"unknown": slog.LevelInfo,
+		" warn ":  slog.LevelWarn,
+		"warning": slog.LevelWarn,
+	} {
+		var logger = newLogger(&output, "", level)
+
+		require.True(t, logger.Enabled(context.Background(), expected), "level '%s'", level)
+		require.False(t, logger.Enabled(context.Background(), expected-1), "level '%s'", level)
+	}
+
+	newLogger(&output, "JSON", "").Info("message", "key", "value")
+
+	require.NoError(t, json.Unmarshal(output.Bytes(), &record))
+	require.Equal(t, "message", record["msg"])
+	require.Equal(t, "value", record["key"])
+
+	output.Reset()
+
+	newLogger(&output, "text", "").Info("message", "key", "value")
+
+	require.Contains(t, output.String(), "msg=message key=value")
+}
@@ -0,0 +1,321 @@
+// Package testimage builds small JPEG images containing Exif, IPTC and XMP metadata, so tests don't have to rely on
+// binary fixtures or an external copy of Exiv2 to produce them.
+package testimage // import "golang.handcraftedbits.com/ezif/internal/testimage"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+//
+// Public types
+//
+
+// Dataset is an IPTC dataset (e.g., record 2, dataset 25 for Iptc.Application2.Keywords).
+type Dataset struct {
+	DataSet byte
+	Record  byte
+	Value   []byte
+}
+
+// Entry is a TIFF directory entry, created using one of the entry functions (e.g., ASCII() or Longs()).
+type Entry struct {
+	count    uint32
+	encode   func(byteOrder binary.ByteOrder) []byte
+	tag      uint16
+	tiffType uint16
+}
+
+// Image describes the metadata of a JPEG image.
+type Image struct {
+	// ByteOrder is the byte order of the Exif metadata, which is little endian if not provided.
+	ByteOrder binary.ByteOrder
+
+	// Exif holds the entries of the first image directory (i.e., Exif.Image properties).
+	Exif []Entry
+
+	// ExifIFD holds the entries of the Exif directory (i.e., Exif.Photo properties).
+	ExifIFD []Entry
+
+	// IPTC holds the IPTC datasets, in the order they're written.
+	IPTC []Dataset
+
+	// XMP is the XMP packet, see XMPPacket().
+	XMP string
+}
+
+// JPEG returns the bytes of a JPEG image containing the metadata.  The image has no frame, which is enough for Exiv2 to
+// read its metadata.  JPEG panics if any metadata segment is larger than the 64KB a JPEG segment can hold.
+func (image *Image) JPEG() []byte {
+	var buffer bytes.Buffer
+
+	buffer.Write([]byte{0xFF, 0xD8})
+
+	if len(image.Exif) > 0 || len(image.ExifIFD) > 0 {
+		writeSegment(&buffer, 0xE1, append([]byte("Exif\x00\x00"), image.tiff()...))
+	}
+
+	if image.XMP != "" {
+		writeSegment(&buffer, 0xE1, append([]byte("http://ns.adobe.com/xap/1.0/\x00"), image.XMP...))
+	}
+
+	if len(image.IPTC) > 0 {
+		writeSegment(&buffer, 0xED, append([]byte("Photoshop 3.0\x00"), image.photoshop()...))
+	}
+
+	buffer.Write([]byte{0xFF, 0xD9})
+
+	return buffer.Bytes()
+}
+
+func (image *Image) getByteOrder() binary.ByteOrder {
+	if image.ByteOrder == nil {
+		return binary.LittleEndian
+	}
+
+	return image.ByteOrder
+}
+
+func (image *Image) photoshop() []byte {
+	var buffer bytes.Buffer
+	var iptc bytes.Buffer
+
+	for _, dataset := range image.IPTC {
+		iptc.Write([]byte{0x1C, dataset.Record, dataset.DataSet})
+
+		_ = binary.Write(&iptc, binary.BigEndian, uint16(len(dataset.Value)))
+
+		iptc.Write(dataset.Value)
+	}
+
+	// A single image resource block (0x0404 is IPTC-NAA) with an empty name, padded to an even length.
+
+	buffer.WriteString("8BIM")
+
+	_ = binary.Write(&buffer, binary.BigEndian, uint16(0x0404))
+
+	buffer.Write([]byte{0x00, 0x00})
+
+	_ = binary.Write(&buffer, binary.BigEndian, uint32(iptc.Len()))
+
+	buffer.Write(iptc.Bytes())
+
+	if iptc.Len()%2 != 0 {
+		buffer.WriteByte(0x00)
+	}
+
+	return buffer.Bytes()
+}
+
+func (image *Image) tiff() []byte {
+	var buffer bytes.Buffer
+	var byteOrder = image.getByteOrder()
+	var ifd0 = append([]Entry(nil), image.Exif...)
+
+	if byteOrder == binary.BigEndian {
+		buffer.WriteString("MM\x00\x2A")
+	} else {
+		buffer.WriteString("II\x2A\x00")
+	}
+
+	_ = binary.Write(&buffer, byteOrder, uint32(tiffHeaderLength))
+
+	if len(image.ExifIFD) == 0 {
+		buffer.Write(writeIFD(byteOrder, ifd0, tiffHeaderLength))
+
+		return buffer.Bytes()
+	}
+
+	// The Exif directory immediately follows the first directory, whose size doesn't depend on the value of the
+	// pointer to the Exif directory.
+
+	ifd0 = append(ifd0, Longs(tagExifIFDPointer, 0))
+	ifd0[len(ifd0)-1] = Longs(tagExifIFDPointer, uint32(tiffHeaderLength+len(writeIFD(byteOrder, ifd0,
+		tiffHeaderLength))))
+
+	buffer.Write(writeIFD(byteOrder, ifd0, tiffHeaderLength))
+	buffer.Write(writeIFD(byteOrder, image.ExifIFD, uint32(buffer.Len())))
+
+	return buffer.Bytes()
+}
+
+//
+// Public functions
+//
+
+// ASCII creates an entry holding a NUL-terminated ASCII string.
+func ASCII(tag uint16, value string) Entry {
+	return Entry{
+		count: uint32(len(value) + 1),
+		encode: func(binary.ByteOrder) []byte {
+			return append([]byte(value), 0x00)
+		},
+		tag:      tag,
+		tiffType: tiffTypeASCII,
+	}
+}
+
+// Longs creates an entry holding unsigned 32-bit integers.
+func Longs(tag uint16, values ...uint32) Entry {
+	return Entry{
+		count: uint32(len(values)),
+		encode: func(byteOrder binary.ByteOrder) []byte {
+			var result = make([]byte, 4*len(values))
+
+			for i, value := range values {
+				byteOrder.PutUint32(result[4*i:], value)
+			}
+
+			return result
+		},
+		tag:      tag,
+		tiffType: tiffTypeLong,
+	}
+}
+
+// Rationals creates an entry holding unsigned rationals, given as numerator and denominator pairs.
+func Rationals(tag uint16, values ...[2]uint32) Entry {
+	return Entry{
+		count: uint32(len(values)),
+		encode: func(byteOrder binary.ByteOrder) []byte {
+			var result = make([]byte, 8*len(values))
+
+			for i, value := range values {
+				byteOrder.PutUint32(result[8*i:], value[0])
+				byteOrder.PutUint32(result[8*i+4:], value[1])
+			}
+
+			return result
+		},
+		tag:      tag,
+		tiffType: tiffTypeRational,
+	}
+}
+
+// Shorts creates an entry holding unsigned 16-bit integers.
+func Shorts(tag uint16, values ...uint16) Entry {
+	return Entry{
+		count: uint32(len(values)),
+		encode: func(byteOrder binary.ByteOrder) []byte {
+			var result = make([]byte, 2*len(values))
+
+			for i, value := range values {
+				byteOrder.PutUint16(result[2*i:], value)
+			}
+
+			return result
+		},
+		tag:      tag,
+		tiffType: tiffTypeShort,
+	}
+}
+
+// Undefined creates an entry holding raw bytes.
+func Undefined(tag uint16, value []byte) Entry {
+	return Entry{
+		count: uint32(len(value)),
+		encode: func(binary.ByteOrder) []byte {
+			return value
+		},
+		tag:      tag,
+		tiffType: tiffTypeUndefined,
+	}
+}
+
+// XMPPacket wraps the given properties in an XMP packet whose single description declares the dc, photoshop and xmp
+// namespaces, e.g.:
+//
+//	testimage.XMPPacket(`<dc:subject><rdf:Bag><rdf:li>a</rdf:li></rdf:Bag></dc:subject>`)
+func XMPPacket(properties string) string {
+	return fmt.Sprintf(xmpPacketFormat, properties)
+}
+
+//
+// Private constants
+//
+
+const (
+	tagExifIFDPointer = 0x8769
+	tiffHeaderLength  = 8
+	tiffTypeASCII     = 2
+	tiffTypeLong      = 4
+	tiffTypeRational  = 5
+	tiffTypeShort     = 3
+	tiffTypeUndefined = 7
+	xmpPacketFormat   = `<?xpacket begin="` + "\uFEFF" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:photoshop="http://ns.adobe.com/photoshop/1.0/"
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/">
+   %s
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+)
+
+//
+// Private functions
+//
+
+func writeIFD(byteOrder binary.ByteOrder, entries []Entry, offset uint32) []byte {
+	var data []byte
+	var dataOffset = offset + 2 + uint32(12*len(entries)) + 4
+	var ifd = make([]byte, 2, dataOffset-offset)
+
+	entries = append([]Entry(nil), entries...)
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].tag < entries[j].tag
+	})
+
+	byteOrder.PutUint16(ifd, uint16(len(entries)))
+
+	for _, entry := range entries {
+		var field = make([]byte, 12)
+		var value = entry.encode(byteOrder)
+
+		byteOrder.PutUint16(field, entry.tag)
+		byteOrder.PutUint16(field[2:], entry.tiffType)
+		byteOrder.PutUint32(field[4:], entry.count)
+
+		// Values that don't fit in the entry are stored after the directory, aligned on a word boundary.
+
+		if len(value) <= 4 {
+			copy(field[8:], value)
+		} else {
+			byteOrder.PutUint32(field[8:], dataOffset+uint32(len(data)))
+
+			data = append(data, value...)
+
+			if len(data)%2 != 0 {
+				data = append(data, 0x00)
+			}
+		}
+
+		ifd = append(ifd, field...)
+	}
+
+	// There's no next directory.
+
+	ifd = append(ifd, 0x00, 0x00, 0x00, 0x00)
+
+	return append(ifd, data...)
+}
+
+func writeSegment(buffer *bytes.Buffer, marker byte, data []byte) {
+	if len(data)+2 > 0xFFFF {
+		panic(fmt.Sprintf("JPEG segment 0x%02X is %d bytes long, but can't be longer than 65533 bytes", marker,
+			len(data)))
+	}
+
+	buffer.Write([]byte{0xFF, marker})
+
+	_ = binary.Write(buffer, binary.BigEndian, uint16(len(data)+2))
+
+	buffer.Write(data)
+}
@@ -0,0 +1,132 @@
+package testimage // import "golang.handcraftedbits.com/ezif/internal/testimage"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+//
+// Public functions
+//
+
+func TestJPEG(t *testing.T) {
+	for _, byteOrder := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		t.Run(byteOrder.String(), func(t *testing.T) {
+			var image = &Image{
+				ByteOrder: byteOrder,
+				Exif: []Entry{
+					ASCII(0x010F, "Canon"),
+					Shorts(0x0102, 8, 8, 8),
+				},
+				ExifIFD: []Entry{
+					Rationals(0x829D, [2]uint32{28, 10}),
+					Undefined(0x9286, []byte("ASCII\x00\x00\x00Hello")),
+				},
+				IPTC: []Dataset{
+					{DataSet: 25, Record: 2, Value: []byte("keyword")},
+				},
+				XMP: XMPPacket("<dc:format>image/jpeg</dc:format>"),
+			}
+			var exifIFD map[uint16][]byte
+			var ifd0 map[uint16][]byte
+			var segments = readSegments(t, image.JPEG())
+			var tiff []byte
+
+			require.Len(t, segments, 3)
+			require.True(t, bytes.HasPrefix(segments[0], []byte("Exif\x00\x00")))
+			require.Equal(t, append([]byte("http://ns.adobe.com/xap/1.0/\x00"), image.XMP...), segments[1])
+			require.Equal(t, []byte("Photoshop 3.0\x008BIM\x04\x04\x00\x00\x00\x00\x00\x0C\x1C\x02\x19\x00\x07keyword"),
+				segments[2])
+
+			tiff = segments[0][6:]
+			ifd0 = readIFD(t, tiff, byteOrder, byteOrder.Uint32(tiff[4:]))
+
+			require.Equal(t, []byte("Canon\x00"), ifd0[0x010F])
+			require.Equal(t, []uint16{8, 8, 8}, []uint16{byteOrder.Uint16(ifd0[0x0102]),
+				byteOrder.Uint16(ifd0[0x0102][2:]), byteOrder.Uint16(ifd0[0x0102][4:])})
+
+			exifIFD = readIFD(t, tiff, byteOrder, byteOrder.Uint32(ifd0[tagExifIFDPointer]))
+
+			require.Equal(t, []uint32{28, 10}, []uint32{byteOrder.Uint32(exifIFD[0x829D]),
+				byteOrder.Uint32(exifIFD[0x829D][4:])})
+			require.Equal(t, []byte("ASCII\x00\x00\x00Hello"), exifIFD[0x9286])
+		})
+	}
+}
+
+func TestJPEGSegmentTooLarge(t *testing.T) {
+	var image = &Image{
+		XMP: string(make([]byte, 0x10000)),
+	}
+
+	require.Panics(t, func() {
+		image.JPEG()
+	})
+}
+
+//
+// Private variables
+//
+
+var tiffTypeSizes = map[uint16]int{
+	tiffTypeASCII:     1,
+	tiffTypeLong:      4,
+	tiffTypeRational:  8,
+	tiffTypeShort:     2,
+	tiffTypeUndefined: 1,
+}
+
+//
+// Private functions
+//
+
+func readIFD(t *testing.T, tiff []byte, byteOrder binary.ByteOrder, offset uint32) map[uint16][]byte {
+	var count = int(byteOrder.Uint16(tiff[offset:]))
+	var previousTag uint16
+	var result = make(map[uint16][]byte)
+
+	for i := 0; i < count; i++ {
+		var field = tiff[int(offset)+2+12*i:]
+		var tag = byteOrder.Uint16(field)
+		var size = tiffTypeSizes[byteOrder.Uint16(field[2:])] * int(byteOrder.Uint32(field[4:]))
+
+		require.Greater(t, tag, previousTag, "directory entries must be sorted by tag")
+
+		previousTag = tag
+
+		if size <= 4 {
+			result[tag] = field[8 : 8+size]
+		} else {
+			var valueOffset = byteOrder.Uint32(field[8:])
+
+			require.Zero(t, valueOffset%2, "value of tag 0x%04X must be word aligned", tag)
+
+			result[tag] = tiff[valueOffset : int(valueOffset)+size]
+		}
+	}
+
+	return result
+}
+
+func readSegments(t *testing.T, jpeg []byte) [][]byte {
+	var result [][]byte
+
+	require.Equal(t, []byte{0xFF, 0xD8}, jpeg[:2])
+	require.Equal(t, []byte{0xFF, 0xD9}, jpeg[len(jpeg)-2:])
+
+	jpeg = jpeg[2 : len(jpeg)-2]
+
+	for len(jpeg) > 0 {
+		var length = int(binary.BigEndian.Uint16(jpeg[2:]))
+
+		require.Equal(t, byte(0xFF), jpeg[0])
+
+		result = append(result, jpeg[4:2+length])
+		jpeg = jpeg[2+length:]
+	}
+
+	return result
+}
@@ -0,0 +1,138 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"context"
+	"iter"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+//
+// Public types
+//
+
+// ReadResult is the result of reading the metadata of a single file with ReadMany.
+type ReadResult struct {
+	// Collection contains the metadata of the file, or nil if the file could not be read.
+	Collection Collection
+
+	// Duration is the amount of time spent reading the file.
+	Duration time.Duration
+
+	// Err contains the error encountered while reading the file, if any.
+	Err error
+
+	// Path is the path of the file.
+	Path string
+}
+
+//
+// Public functions
+//
+
+// ReadMany reads the metadata of every file path yielded by paths using a bounded pool of workers (see Workers()),
+// sending a ReadResult for each file to the returned channel.  Results are sent in the order in which files finish
+// being read, which is not necessarily the order in which paths were yielded.  The returned channel is closed once
+// paths is exhausted and all files have been read.  Paths are pulled from paths by a single goroutine, so the iterator
+// doesn't need to be safe for concurrent use, e.g.:
+//
+//	for result := range metadata.ReadMany(ctx, slices.Values(filenames)) {
+//		...
+//	}
+//
+// Cancelling ctx stops iterating over paths and stops the workers once they finish reading their current file, after
+// which the returned channel is closed.  Workers block until their results are received, so ctx must be cancelled
+// when giving up on the results before the channel is closed (e.g., when breaking out of the loop above), otherwise
+// the goroutines reading files are leaked.
+func ReadMany(ctx context.Context, paths iter.Seq[string], options ...ReadOption) <-chan ReadResult {
+	var pathChannel = make(chan string)
+	var readOptions = newReadOptions(options)
+	var results = make(chan ReadResult, readOptions.workers)
+	var waitGroup sync.WaitGroup
+
+	// Reads log using ctx, so that context-aware log handlers can make use of it.
+
+	readOptions.ctx = ctx
+
+	waitGroup.Add(readOptions.workers)
+
+	go func() {
+		defer close(pathChannel)
+
+		for path := range paths {
+			select {
+			case <-ctx.Done():
+				return
+
+			case pathChannel <- path:
+			}
+		}
+	}()
+
+	for i := 0; i < readOptions.workers; i++ {
+		go func() {
+			defer waitGroup.Done()
+
+			readManyWorker(ctx, pathChannel, results, readOptions)
+		}()
+	}
+
+	go func() {
+		waitGroup.Wait()
+
+		close(results)
+	}()
+
+	return results
+}
+
+//
+// Private functions
+//
+
+func readManyWorker(ctx context.Context, paths <-chan string, results chan<- ReadResult, options *readOptions) {
+	for {
+		var path string
+		var ok bool
+		var result ReadResult
+		var start time.Time
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case path, ok = <-paths:
+			if !ok {
+				return
+			}
+		}
+
+		// Receiving a path can race with cancellation, so check again before doing any work.
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		start = time.Now()
+
+		result.Collection, result.Err = readCollection(&imageSource{kind: imageSourceFile, location: path}, options)
+		result.Duration = time.Since(start)
+		result.Path = path
+
+		if options.logger.Enabled(ctx, slog.LevelDebug) {
+			options.logger.DebugContext(ctx, "read image metadata in batch",
+				"duration", result.Duration,
+				"error", result.Err,
+				"filename", path,
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+
+		case results <- result:
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+//
+// Public functions
+//
+
+func TestReadMany(t *testing.T) {
+	var directory = t.TempDir()
+	var filenames = make([]string, testReadImages)
+	var seen = make(map[string]bool)
+
+	for i := range filenames {
+		filenames[i] = filepath.Join(directory, fmt.Sprintf("image%d.jpg", i))
+
+		require.NoError(t, os.WriteFile(filenames[i], newTestImage(i).JPEG(), 0600))
+	}
+
+	// A missing file is reported as a result rather than stopping the batch.
+
+	filenames = append(filenames, filepath.Join(directory, "missing.jpg"))
+
+	for result := range ReadMany(context.Background(), slices.Values(filenames), Workers(4)) {
+		var index = slices.Index(filenames, result.Path)
+
+		require.NotEqual(t, -1, index)
+		require.False(t, seen[result.Path], "file '%s' was read more than once", result.Path)
+
+		seen[result.Path] = true
+
+		if index == testReadImages {
+			require.Error(t, result.Err)
+			require.Nil(t, result.Collection)
+
+			continue
+		}
+
+		require.NoError(t, result.Err)
+		require.NoError(t, checkTestImage(result.Collection, index))
+	}
+
+	require.Len(t, seen, len(filenames))
+}
+
+// TestReadManyAbandoned checks that results don't need to be drained once ctx has been cancelled.
+func TestReadManyAbandoned(t *testing.T) {
+	var ctx, cancel = context.WithCancel(context.Background())
+	var iterated = make(chan struct{})
+	var results = ReadMany(ctx, func(yield func(string) bool) {
+		defer close(iterated)
+
+		for yield("missing.jpg") {
+		}
+	}, Workers(2))
+
+	defer cancel()
+
+	<-results
+
+	// Stop receiving results: the workers are now blocked sending theirs until ctx is cancelled.
+
+	cancel()
+
+	select {
+	case <-iterated:
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("paths are still being iterated after cancellation")
+	}
+
+	// Only results that were already buffered, plus one result per worker that was being read when ctx was cancelled,
+	// can be received before the channel is closed.
+
+	for i := 0; ; i++ {
+		select {
+		case _, ok := <-results:
+			if !ok {
+				return
+			}
+
+			require.Less(t, i, 4, "results are still being sent after cancellation")
+
+		case <-time.After(5 * time.Second):
+			t.Fatal("results channel wasn't closed after cancellation")
+		}
+	}
+}
+
+func TestReadManyCancelled(t *testing.T) {
+	var ctx, cancel = context.WithCancel(context.Background())
+	var iterated = make(chan struct{})
+
+	cancel()
+
+	// The results channel must be closed even though paths never ends, and paths must stop being iterated.
+
+	for range ReadMany(ctx, func(yield func(string) bool) {
+		defer close(iterated)
+
+		for yield("missing.jpg") {
+		}
+	}) {
+	}
+
+	select {
+	case <-iterated:
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("paths are still being iterated after cancellation")
+	}
+}
+
+func TestReadManyLogContext(t *testing.T) {
+	type contextKey struct{}
+
+	var ctx = context.WithValue(context.Background(), contextKey{}, "batch")
+	var handler = &contextTestHandler{}
+
+	for range ReadMany(ctx, slices.Values([]string{"missing.jpg"}), Logger(slog.New(handler))) {
+	}
+
+	// Everything logged while reading gets the context of the batch.
+
+	require.NotEmpty(t, handler.contexts)
+
+	for _, logContext := range handler.contexts {
+		require.Equal(t, "batch", logContext.Value(contextKey{}))
+	}
+}
+
+//
+// Private types
+//
+
+// contextTestHandler is a slog.Handler that records the context of each log record.
+type contextTestHandler struct {
+	contexts []context.Context
+	mutex    sync.Mutex
+}
+
+func (handler *contextTestHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (handler *contextTestHandler) Handle(ctx context.Context, _ slog.Record) error {
+	handler.mutex.Lock()
+
+	defer handler.mutex.Unlock()
+
+	handler.contexts = append(handler.contexts, ctx)
+
+	return nil
+}
+
+func (handler *contextTestHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return handler
+}
+
+func (handler *contextTestHandler) WithGroup(string) slog.Handler {
+	return handler
+}
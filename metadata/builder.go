@@ -0,0 +1,353 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+//
+// Public types
+//
+
+// Builder builds a Collection from typed values instead of reading it from an image, which is mostly useful for
+// testing code that consumes metadata.  Values are given using the same types that Property.Value() returns, either as
+// a slice (e.g., []uint16 for Exif.Image.BitsPerSample) or, for convenience, as a single value (e.g., "Canon" for
+// Exif.Image.Make or a map[string]string for an XMP language alternative):
+//
+//	var collection, err = metadata.NewBuilder().
+//		Set("Exif.Image.Make", "Canon").
+//		Set("Exif.Photo.FNumber", big.NewRat(28, 10)).
+//		Set("Iptc.Application2.Keywords", []string{"a", "b"}).
+//		Set("Xmp.dc.title", map[string]string{"x-default": "Title"}).
+//		Build()
+//
+// Properties are validated against the definitions known to Exiv2: keys must refer to known properties and values must
+// have the type Exiv2 uses for the property.  Exiv2 has no definitions for XMP namespaces registered at runtime (see
+// RegisterNamespace()), so any property of such a namespace is accepted, using the definitions given to
+// RegisterNamespace() or XMP text otherwise.  Values are copied, so changing them after they were set doesn't affect
+// the builder.  Errors are collected and reported by Build().
+type Builder struct {
+	byteOrder  binary.ByteOrder
+	errs       []error
+	keys       []string
+	properties map[string]*builderProperty
+	warnings   []Warning
+}
+
+// AddWarning adds a warning to the collection, as if it was reported by Exiv2.
+func (builder *Builder) AddWarning(warning Warning) *Builder {
+	builder.warnings = append(builder.warnings, warning)
+
+	return builder
+}
+
+// Build returns a new Collection containing the properties set so far, or an error describing every property that
+// couldn't be set.  The builder can still be used afterwards, without affecting previously built collections.
+func (builder *Builder) Build() (Collection, error) {
+	var collection = newCollection()
+
+	if len(builder.errs) > 0 {
+		return nil, errors.Join(builder.errs...)
+	}
+
+	collection.exifProperties.byteOrder = builder.byteOrder
+	collection.warnings = append([]Warning(nil), builder.warnings...)
+
+	for _, key := range builder.keys {
+		var builderProperty = builder.properties[key]
+		var property = newProperty(builderProperty.family, builderProperty.groupName, builderProperty.tagName,
+			builderProperty.typeId, builderProperty.label, builderProperty.interpretedValue, builderProperty.repeatable)
+
+		property.tagNumber = builderProperty.tagNumber
+		property.value = builderProperty.value
+
+		// IPTC strings are built as if they were read from an image using UTF-8.
+
+		if strs, ok := builderProperty.value.([]string); ok && builderProperty.typeId == types.IDIPTCString {
+			property.rawBytes = make([][]byte, len(strs))
+
+			for i, str := range strs {
+				property.rawBytes[i] = []byte(str)
+			}
+		}
+
+		switch builderProperty.family {
+		case FamilyExif:
+			collection.exifProperties.add(property)
+
+		case FamilyIPTC:
+			collection.iptcProperties.add(property)
+
+		case FamilyXMP:
+			collection.xmpProperties.add(property)
+		}
+	}
+
+	collection.exifProperties.finish()
+	collection.iptcProperties.finish()
+	collection.xmpProperties.finish()
+
+	return collection, nil
+}
+
+// ByteOrder sets the byte order reported for Exif properties (see Properties.ByteOrder()), which is little endian by
+// default.
+func (builder *Builder) ByteOrder(byteOrder binary.ByteOrder) *Builder {
+	builder.byteOrder = byteOrder
+
+	return builder
+}
+
+// Set sets the value of the property with the given key, which must have the type Exiv2 uses for the property.  Setting
+// a property again replaces its value, even for repeatable IPTC datasets (e.g., Iptc.Application2.Keywords), whose
+// values are all given at once; other IPTC datasets only accept a single value.  The interpreted value of the property
+// is the value formatted the way Exiv2 formats values it has no specific interpretation for (e.g., "1 2" or "1/200");
+// use SetInterpreted() to change it.
+func (builder *Builder) Set(key string, value interface{}) *Builder {
+	var info, err = lookupTag(key)
+
+	if err != nil {
+		return builder.addError(fmt.Errorf("unknown metadata property '%s': %w", key, err))
+	}
+
+	return builder.set(key, info, value)
+}
+
+// SetInterpreted sets the interpreted value of a property that was already set.
+func (builder *Builder) SetInterpreted(key, interpretedValue string) *Builder {
+	var property, ok = builder.properties[key]
+
+	if !ok {
+		return builder.addError(fmt.Errorf("cannot set interpreted value of metadata property '%s' before its value",
+			key))
+	}
+
+	property.interpretedValue = interpretedValue
+
+	return builder
+}
+
+// SetWithType is like Set, but uses the given type instead of the one Exiv2 uses for the property.  This allows
+// building properties the way they're found in images that don't follow the specifications (e.g., an
+// Exif.Photo.UserComment of type undefined).
+func (builder *Builder) SetWithType(key string, typeID types.ID, value interface{}) *Builder {
+	var info, err = lookupTag(key)
+
+	if err != nil {
+		return builder.addError(fmt.Errorf("unknown metadata property '%s': %w", key, err))
+	}
+
+	info.typeId = typeID
+
+	return builder.set(key, info, value)
+}
+
+func (builder *Builder) addError(err error) *Builder {
+	builder.errs = append(builder.errs, err)
+
+	return builder
+}
+
+func (builder *Builder) set(key string, info *tagInfo, value interface{}) *Builder {
+	var err error
+	var parts = strings.SplitN(key, ".", 3)
+	var property *builderProperty
+
+	if len(parts) != 3 {
+		return builder.addError(fmt.Errorf("invalid metadata property key '%s'", key))
+	}
+
+	property = &builderProperty{
+		family:     Family(parts[0]),
+		groupName:  parts[1],
+		label:      info.label,
+		repeatable: info.repeatable,
+		tagName:    parts[2],
+		tagNumber:  info.tagNumber,
+		typeId:     info.typeId,
+	}
+
+	if property.value, err = getBuilderValue(info.typeId, value); err != nil {
+		return builder.addError(fmt.Errorf("invalid value for metadata property '%s': %w", key, err))
+	}
+
+	// Only repeatable IPTC datasets can have more than one value when read from an image (see propertiesImpl.add()).
+
+	if property.family == FamilyIPTC && !property.repeatable && reflect.ValueOf(property.value).Len() > 1 {
+		return builder.addError(fmt.Errorf("metadata property '%s' isn't repeatable and can only have a single value",
+			key))
+	}
+
+	property.interpretedValue = formatBuilderValue(property.typeId, property.value)
+
+	if _, ok := builder.properties[key]; !ok {
+		builder.keys = append(builder.keys, key)
+	}
+
+	builder.properties[key] = property
+
+	return builder
+}
+
+//
+// Public functions
+//
+
+// NewBuilder creates a Builder for an empty collection.
+func NewBuilder() *Builder {
+	return &Builder{
+		byteOrder:  binary.LittleEndian,
+		properties: make(map[string]*builderProperty),
+	}
+}
+
+//
+// Private types
+//
+
+type builderProperty struct {
+	family           Family
+	groupName        string
+	interpretedValue string
+	label            string
+	repeatable       bool
+	tagName          string
+	tagNumber        uint16
+	typeId           types.ID
+	value            interface{}
+}
+
+//
+// Private variables
+//
+
+// The types of property values, as returned by Property.Value().
+var builderValueTypes = map[types.ID]reflect.Type{
+	types.IDAsciiString:      reflect.TypeOf([]string(nil)),
+	types.IDComment:          reflect.TypeOf([]string(nil)),
+	types.IDIPTCDate:         reflect.TypeOf([]types.IPTCDate(nil)),
+	types.IDIPTCString:       reflect.TypeOf([]string(nil)),
+	types.IDIPTCTime:         reflect.TypeOf([]types.IPTCTime(nil)),
+	types.IDSignedByte:       reflect.TypeOf([]int8(nil)),
+	types.IDSignedLong:       reflect.TypeOf([]int32(nil)),
+	types.IDSignedRational:   reflect.TypeOf([]*big.Rat(nil)),
+	types.IDSignedShort:      reflect.TypeOf([]int16(nil)),
+	types.IDTIFFDouble:       reflect.TypeOf([]float64(nil)),
+	types.IDTIFFFloat:        reflect.TypeOf([]float32(nil)),
+	types.IDUndefined:        reflect.TypeOf([]byte(nil)),
+	types.IDUnsignedByte:     reflect.TypeOf([]byte(nil)),
+	types.IDUnsignedLong:     reflect.TypeOf([]uint32(nil)),
+	types.IDUnsignedRational: reflect.TypeOf([]*big.Rat(nil)),
+	types.IDUnsignedShort:    reflect.TypeOf([]uint16(nil)),
+	types.IDXMPAlt:           reflect.TypeOf([]string(nil)),
+	types.IDXMPBag:           reflect.TypeOf([]string(nil)),
+	types.IDXMPLangAlt:       reflect.TypeOf([]map[string]string(nil)),
+	types.IDXMPSeq:           reflect.TypeOf([]string(nil)),
+	types.IDXMPText:          reflect.TypeOf([]string(nil)),
+}
+
+//
+// Private functions
+//
+
+// copyBuilderValue copies a single value if it could otherwise be modified through the caller's reference to it.
+func copyBuilderValue(value reflect.Value) reflect.Value {
+	switch source := value.Interface().(type) {
+	case *big.Rat:
+		if source != nil {
+			return reflect.ValueOf(new(big.Rat).Set(source))
+		}
+
+	case map[string]string:
+		return reflect.ValueOf(maps.Clone(source))
+	}
+
+	return value
+}
+
+func formatBuilderValue(typeId types.ID, value interface{}) string {
+	var separator = " "
+	var strs []string
+	var values = reflect.ValueOf(value)
+
+	switch typeId {
+	case types.IDXMPAlt, types.IDXMPBag, types.IDXMPSeq:
+		separator = ", "
+
+	case types.IDXMPLangAlt:
+		var languages []string
+		var langAlt = value.([]map[string]string)[0]
+
+		for language := range langAlt {
+			languages = append(languages, language)
+		}
+
+		// Exiv2 lists the default language first.
+
+		sort.Slice(languages, func(i, j int) bool {
+			if languages[i] == xmpLanguageDefault || languages[j] == xmpLanguageDefault {
+				return languages[i] == xmpLanguageDefault
+			}
+
+			return languages[i] < languages[j]
+		})
+
+		for _, language := range languages {
+			strs = append(strs, fmt.Sprintf("lang=\"%s\" %s", language, langAlt[language]))
+		}
+
+		return strings.Join(strs, ", ")
+	}
+
+	for i := 0; i < values.Len(); i++ {
+		strs = append(strs, fmt.Sprint(values.Index(i).Interface()))
+	}
+
+	return strings.Join(strs, separator)
+}
+
+func getBuilderValue(typeId types.ID, value interface{}) (interface{}, error) {
+	var result reflect.Value
+	var sliceType, ok = builderValueTypes[typeId]
+	var sourceValue = reflect.ValueOf(value)
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported type %s", typeId)
+	}
+
+	switch {
+	case !sourceValue.IsValid():
+		return nil, fmt.Errorf("expected %s, found nil", sliceType)
+
+	// Values are copied so that the caller can't modify the properties of built collections afterwards.
+
+	case sourceValue.Type().AssignableTo(sliceType):
+		result = reflect.MakeSlice(sliceType, sourceValue.Len(), sourceValue.Len())
+
+		for i := 0; i < sourceValue.Len(); i++ {
+			result.Index(i).Set(copyBuilderValue(sourceValue.Index(i)))
+		}
+
+	case sourceValue.Type().AssignableTo(sliceType.Elem()):
+		result = reflect.MakeSlice(sliceType, 1, 1)
+
+		result.Index(0).Set(copyBuilderValue(sourceValue))
+
+	default:
+		return nil, fmt.Errorf("expected %s or %s, found %T", sliceType, sliceType.Elem(), value)
+	}
+
+	if typeId == types.IDXMPLangAlt && result.Len() != 1 {
+		return nil, fmt.Errorf("expected a single language alternative, found %d", result.Len())
+	}
+
+	return result.Interface(), nil
+}
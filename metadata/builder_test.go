@@ -0,0 +1,195 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"encoding/binary"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+//
+// Public functions
+//
+
+func TestBuilder(t *testing.T) {
+	var collection, err = NewBuilder().
+		ByteOrder(binary.BigEndian).
+		Set("Exif.Image.Make", "Canon").
+		Set("Exif.Image.BitsPerSample", []uint16{8, 8, 8}).
+		Set("Exif.Photo.FNumber", big.NewRat(28, 10)).
+		SetInterpreted("Exif.Photo.FNumber", "F2.8").
+		Set("Iptc.Application2.DateCreated", types.NewIPTCDate(2020, time.March, 14)).
+		Set("Iptc.Application2.Keywords", []string{"one", "two"}).
+		Set("Xmp.dc.subject", []string{"three", "four"}).
+		Set("Xmp.dc.title", map[string]string{"x-default": "Title", "de": "Titel"}).
+		AddWarning(Warning{Message: "warning", Severity: SeverityWarning}).
+		Build()
+	var property Property
+
+	require.NoError(t, err)
+	require.Equal(t, binary.BigEndian, collection.Exif().ByteOrder())
+	require.Equal(t, []Warning{{Message: "warning", Severity: SeverityWarning}}, collection.Warnings())
+
+	property = collection.Exif().Get("Exif.Image.Make")
+
+	require.Equal(t, types.IDAsciiString, property.TypeID())
+	require.Equal(t, uint16(0x010f), property.TagNumber())
+	require.Equal(t, []string{"Canon"}, property.Value())
+	require.Equal(t, "Canon", property.InterpretedValue())
+
+	property = collection.Exif().Get("Exif.Image.BitsPerSample")
+
+	require.Equal(t, []uint16{8, 8, 8}, property.Value())
+	require.Equal(t, "8 8 8", property.InterpretedValue())
+
+	require.Equal(t, "F2.8", collection.Exif().Get("Exif.Photo.FNumber").InterpretedValue())
+
+	property = collection.IPTC().Get("Iptc.Application2.Keywords")
+
+	require.Equal(t, []string{"one", "two"}, property.Value())
+	require.Equal(t, [][]byte{[]byte("one"), []byte("two")}, property.RawBytes())
+
+	require.Equal(t, []map[string]string{{"x-default": "Title", "de": "Titel"}},
+		collection.XMP().Get("Xmp.dc.title").Value())
+	require.Equal(t, `lang="x-default" Title, lang="de" Titel`,
+		collection.XMP().Get("Xmp.dc.title").InterpretedValue())
+	require.Equal(t, "three, four", collection.XMP().Get("Xmp.dc.subject").InterpretedValue())
+}
+
+func TestBuilderCopiesValues(t *testing.T) {
+	var builder = NewBuilder()
+	var collection Collection
+	var err error
+	var fNumber = big.NewRat(28, 10)
+	var keywords = []string{"one", "two"}
+	var title = map[string]string{"x-default": "Title"}
+
+	builder.
+		Set("Exif.Photo.FNumber", fNumber).
+		Set("Iptc.Application2.Keywords", keywords).
+		Set("Xmp.dc.title", title)
+
+	fNumber.SetInt64(4)
+	keywords[0] = "changed"
+	title["x-default"] = "Changed"
+
+	collection, err = builder.Build()
+
+	require.NoError(t, err)
+	require.Equal(t, 0, big.NewRat(28, 10).Cmp(collection.Exif().Get("Exif.Photo.FNumber").Value().([]*big.Rat)[0]))
+	require.Equal(t, []string{"one", "two"}, collection.IPTC().Get("Iptc.Application2.Keywords").Value())
+	require.Equal(t, []map[string]string{{"x-default": "Title"}}, collection.XMP().Get("Xmp.dc.title").Value())
+
+	// Building again doesn't affect previously built collections.
+
+	_, err = builder.Set("Iptc.Application2.Keywords", "three").Build()
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"one", "two"}, collection.IPTC().Get("Iptc.Application2.Keywords").Value())
+}
+
+func TestBuilderErrors(t *testing.T) {
+	var err error
+	var tests = []struct {
+		build func(builder *Builder) *Builder
+		name  string
+	}{
+		{
+			build: func(builder *Builder) *Builder {
+				return builder.Set("Exif.Image.NoSuchTag", "value")
+			},
+			name: "UnknownExifKey",
+		},
+		{
+			build: func(builder *Builder) *Builder {
+				return builder.Set("Iptc.Application2.NoSuchDataset", "value")
+			},
+			name: "UnknownIPTCKey",
+		},
+		{
+			build: func(builder *Builder) *Builder {
+				return builder.Set("Xmp.dc.noSuchProperty", "value")
+			},
+			name: "UnknownXMPProperty",
+		},
+		{
+			build: func(builder *Builder) *Builder {
+				return builder.Set("Exif.Photo.FNumber", 2.8)
+			},
+			name: "WrongType",
+		},
+		{
+			build: func(builder *Builder) *Builder {
+				return builder.Set("Exif.Image.BitsPerSample", []int{8, 8, 8})
+			},
+			name: "WrongSliceType",
+		},
+		{
+			build: func(builder *Builder) *Builder {
+				return builder.Set("Exif.Image.Make", nil)
+			},
+			name: "Nil",
+		},
+		{
+			build: func(builder *Builder) *Builder {
+				return builder.Set("Xmp.dc.title", []map[string]string{{"x-default": "One"}, {"x-default": "Two"}})
+			},
+			name: "MultipleLanguageAlternatives",
+		},
+		{
+			build: func(builder *Builder) *Builder {
+				return builder.Set("Iptc.Application2.DateCreated", []types.IPTCDate{
+					types.NewIPTCDate(2020, time.March, 14),
+					types.NewIPTCDate(2021, time.March, 14),
+				})
+			},
+			name: "NotRepeatable",
+		},
+		{
+			build: func(builder *Builder) *Builder {
+				return builder.SetInterpreted("Exif.Image.Make", "Canon")
+			},
+			name: "InterpretedBeforeValue",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var collection, err = test.build(NewBuilder()).Build()
+
+			require.Error(t, err)
+			require.Nil(t, collection)
+		})
+	}
+
+	// Every error is reported, not just the first one.
+
+	_, err = NewBuilder().
+		Set("Exif.Image.NoSuchTag", "value").
+		Set("Exif.Photo.FNumber", 2.8).
+		Set("Exif.Image.Make", "Canon").
+		Build()
+
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Exif.Image.NoSuchTag")
+	require.Contains(t, err.Error(), "Exif.Photo.FNumber")
+	require.NotContains(t, err.Error(), "Exif.Image.Make")
+}
+
+func TestBuilderRepeatable(t *testing.T) {
+	var collection, err = NewBuilder().
+		Set("Iptc.Application2.Keywords", []string{"one", "two"}).
+		Set("Iptc.Application2.Keywords", []string{"three"}).
+		Set("Iptc.Application2.DateCreated", types.NewIPTCDate(2020, time.March, 14)).
+		Build()
+
+	// Setting a repeatable dataset again replaces its values rather than adding to them.
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"three"}, collection.IPTC().Get("Iptc.Application2.Keywords").Value())
+	require.Len(t, collection.IPTC().Get("Iptc.Application2.DateCreated").Value(), 1)
+}
@@ -0,0 +1,185 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+//
+// Private types
+//
+
+type iptcCharset int
+
+func (charset iptcCharset) String() string {
+	switch charset {
+	case iptcCharsetASCII:
+		return "ASCII"
+
+	case iptcCharsetISO88591:
+		return "ISO-8859-1"
+
+	case iptcCharsetUTF8:
+		return "UTF-8"
+
+	case iptcCharsetWindows1252:
+		return "Windows-1252"
+	}
+
+	return "unknown"
+}
+
+//
+// Private constants
+//
+
+const (
+	iptcCharsetASCII iptcCharset = iota + 1
+	iptcCharsetISO88591
+	iptcCharsetUTF8
+	iptcCharsetWindows1252
+)
+
+// The name of the IPTC dataset that declares the coded character set (1:90).
+const iptcKeyCharacterSet = "Iptc.Envelope.CharacterSet"
+
+//
+// Private variables
+//
+
+// ISO 2022 escape sequences that can appear in the IPTC coded character set dataset.  See section 1.5.10 of the IPTC
+// IIM specification.  A dataset may hold several designations (e.g. ASCII in G0 and ISO-8859-1 in G1), in which case
+// the one that appears last in this table wins.
+var iptcCharsetEscapes = []struct {
+	charset iptcCharset
+	escape  []byte
+}{
+	{charset: iptcCharsetASCII, escape: []byte("\x1b(B")},
+	{charset: iptcCharsetISO88591, escape: []byte("\x1b-A")},
+	{charset: iptcCharsetISO88591, escape: []byte("\x1b.A")},
+	{charset: iptcCharsetUTF8, escape: []byte("\x1b%G")},
+}
+
+// Windows-1252 differs from ISO-8859-1 only in the 0x80-0x9F range, which ISO-8859-1 reserves for control characters.
+// Unassigned code points are mapped to the Unicode replacement character.
+var windows1252Runes = [32]rune{
+	'€', utf8.RuneError, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', utf8.RuneError, 'Ž', utf8.RuneError,
+	utf8.RuneError, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', utf8.RuneError, 'ž', 'Ÿ',
+}
+
+//
+// Private functions
+//
+
+// decodeIPTCString converts a raw IPTC string value to UTF-8 according to the given character set.  Writers don't
+// always honor the character set they declare, so an ASCII or UTF-8 value that isn't valid UTF-8 is decoded as
+// Windows-1252, just like a value without a declared character set would be.
+func decodeIPTCString(value []byte, charset iptcCharset) string {
+	var builder strings.Builder
+
+	switch charset {
+	case iptcCharsetASCII, iptcCharsetUTF8:
+		// ASCII is a subset of UTF-8, so valid values can be passed through as-is.
+
+		if utf8.Valid(value) {
+			return string(value)
+		}
+
+	case iptcCharsetISO88591:
+		builder.Grow(len(value))
+
+		for _, b := range value {
+			builder.WriteRune(rune(b))
+		}
+
+		return builder.String()
+	}
+
+	builder.Grow(len(value))
+
+	for _, b := range value {
+		if b >= 0x80 && b <= 0x9F {
+			builder.WriteRune(windows1252Runes[b-0x80])
+		} else {
+			builder.WriteRune(rune(b))
+		}
+	}
+
+	return builder.String()
+}
+
+// detectIPTCCharset determines the character set used to encode IPTC string values.  If the coded character set
+// dataset declares a character set we understand we'll use it, otherwise we'll take a guess based on the values
+// themselves: anything that is valid UTF-8 is assumed to be UTF-8, and anything else is assumed to be Windows-1252 (a
+// superset of ISO-8859-1), which is what the vast majority of legacy IPTC writers used.  An error is returned along
+// with the guess if the declared character set isn't supported.
+func detectIPTCCharset(declared []byte, values [][]byte) (iptcCharset, error) {
+	var err error
+
+	if len(declared) > 0 {
+		var charset iptcCharset
+
+		if charset, err = parseIPTCCharset(declared); err == nil {
+			return charset, nil
+		}
+	}
+
+	for _, value := range values {
+		if !utf8.Valid(value) {
+			return iptcCharsetWindows1252, err
+		}
+	}
+
+	return iptcCharsetUTF8, err
+}
+
+// parseIPTCCharset parses the ISO 2022 escape sequences of the IPTC coded character set dataset in order.  Each escape
+// sequence consists of ESC, any number of intermediate bytes (0x20-0x2F) and a final byte (0x30-0x7E).
+func parseIPTCCharset(declared []byte) (iptcCharset, error) {
+	var charset iptcCharset
+	var precedence = -1
+	var remaining = declared
+
+	for len(remaining) > 0 {
+		var found = -1
+		var length = 1
+
+		if remaining[0] != 0x1b {
+			return 0, fmt.Errorf("unsupported IPTC coded character set %q: expected escape sequence", declared)
+		}
+
+		for length < len(remaining) && remaining[length] >= 0x20 && remaining[length] <= 0x2F {
+			length++
+		}
+
+		if length == 1 || length == len(remaining) || remaining[length] < 0x30 || remaining[length] > 0x7E {
+			return 0, fmt.Errorf("unsupported IPTC coded character set %q: invalid escape sequence", declared)
+		}
+
+		length++
+
+		for i, escape := range iptcCharsetEscapes {
+			if bytes.Equal(remaining[:length], escape.escape) {
+				found = i
+
+				break
+			}
+		}
+
+		if found < 0 {
+			return 0, fmt.Errorf("unsupported IPTC coded character set %q: unknown escape sequence %q", declared,
+				remaining[:length])
+		}
+
+		if found > precedence {
+			charset = iptcCharsetEscapes[found].charset
+			precedence = found
+		}
+
+		remaining = remaining[length:]
+	}
+
+	return charset, nil
+}
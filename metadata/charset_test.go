@@ -0,0 +1,124 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+//
+// Public functions
+//
+
+func TestDecodeIPTCString(t *testing.T) {
+	for _, test := range []struct {
+		charset  iptcCharset
+		expected string
+		name     string
+		value    string
+	}{
+		{charset: iptcCharsetASCII, expected: "plain", name: "ASCII", value: "plain"},
+		{charset: iptcCharsetASCII, expected: "café", name: "ASCII holding Latin-1", value: "caf\xe9"},
+		{charset: iptcCharsetISO88591, expected: "café \u0080", name: "ISO-8859-1", value: "caf\xe9 \x80"},
+		{charset: iptcCharsetUTF8, expected: "café", name: "UTF-8", value: "café"},
+		{charset: iptcCharsetUTF8, expected: "café", name: "UTF-8 holding Latin-1", value: "caf\xe9"},
+		{
+			charset:  iptcCharsetWindows1252,
+			expected: "€ café ‚ Ÿ",
+			name:     "Windows-1252",
+			value:    "\x80 caf\xe9 \x82 \x9f",
+		},
+		{
+			charset:  iptcCharsetWindows1252,
+			expected: "�����",
+			name:     "Windows-1252 undefined",
+			value:    "\x81\x8d\x8f\x90\x9d",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var decoded = decodeIPTCString([]byte(test.value), test.charset)
+
+			require.Equal(t, test.expected, decoded)
+			require.True(t, utf8.ValidString(decoded))
+		})
+	}
+}
+
+func TestDecodeIPTCStringsUnsupported(t *testing.T) {
+	var buffer = &testBuffer{}
+	var collection *collectionImpl
+	var err error
+	var output bytes.Buffer
+
+	buffer.uint8(bufferVersion)
+	buffer.uint8(byteOrderBigEndian)
+	buffer.property(familyCodeIPTC, "Envelope", "CharacterSet", 90, "\x1b$B", types.IDIPTCString, 0, 1,
+		func(values *testBuffer) {
+			values.string("\x1b$B")
+		})
+	buffer.property(familyCodeIPTC, "Application2", "Keywords", 25, "caf\xe9", types.IDIPTCString,
+		propertyFlagRepeatable, 1, func(values *testBuffer) {
+			values.string("caf\xe9")
+		})
+
+	collection, err = decodeCollection(buffer.data,
+		newReadOptions([]ReadOption{Logger(slog.New(slog.NewTextHandler(&output, nil)))}))
+
+	// An unsupported character set is reported and the values are decoded using a guess instead.
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"café"}, collection.IPTC().Get("Iptc.Application2.Keywords").Value())
+	require.Contains(t, output.String(), "level=WARN")
+	require.Contains(t, output.String(), "could not use declared IPTC character set")
+	require.Contains(t, output.String(), "charset=Windows-1252")
+}
+
+func TestDetectIPTCCharset(t *testing.T) {
+	var latin1 = [][]byte{[]byte("caf\xe9")}
+	var utf8Values = [][]byte{[]byte("café"), []byte("plain")}
+
+	for _, test := range []struct {
+		declared string
+		expected iptcCharset
+		invalid  bool
+		name     string
+		values   [][]byte
+	}{
+		{declared: "\x1b(B", expected: iptcCharsetASCII, name: "ASCII", values: latin1},
+		{declared: "\x1b-A", expected: iptcCharsetISO88591, name: "ISO-8859-1 in G1", values: utf8Values},
+		{declared: "\x1b.A", expected: iptcCharsetISO88591, name: "ISO-8859-1 in G2", values: utf8Values},
+		{declared: "\x1b%G", expected: iptcCharsetUTF8, name: "UTF-8", values: latin1},
+		{declared: "\x1b(B\x1b-A", expected: iptcCharsetISO88591, name: "ASCII in G0, ISO-8859-1 in G1"},
+		{declared: "\x1b-A\x1b(B", expected: iptcCharsetISO88591, name: "ISO-8859-1 in G1, ASCII in G0"},
+		{expected: iptcCharsetUTF8, name: "undeclared UTF-8", values: utf8Values},
+		{expected: iptcCharsetWindows1252, name: "undeclared Latin-1", values: append(utf8Values, latin1...)},
+		{expected: iptcCharsetWindows1252, name: "undeclared Windows-1252", values: [][]byte{[]byte("\x93quoted\x94")}},
+		{
+			declared: "\x1b$B",
+			expected: iptcCharsetWindows1252,
+			invalid:  true,
+			name:     "unsupported",
+			values:   latin1,
+		},
+		{declared: "\x1b(B\x1b$)A", expected: iptcCharsetUTF8, invalid: true, name: "partially unsupported"},
+		{declared: "UTF-8", expected: iptcCharsetUTF8, invalid: true, name: "not an escape sequence"},
+		{declared: "\x1b(", expected: iptcCharsetUTF8, invalid: true, name: "truncated escape sequence"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var charset, err = detectIPTCCharset([]byte(test.declared), test.values)
+
+			require.Equal(t, test.expected, charset)
+
+			if test.invalid {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
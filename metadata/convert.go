@@ -0,0 +1,124 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+//
+// Public functions
+//
+
+// DateTime combines the values of a date property and a time property into a single time, and returns whether the date
+// property exists.  This is needed for metadata that stores dates and times separately, e.g.:
+//
+//	Iptc.Application2.DateCreated and Iptc.Application2.TimeCreated
+//	Iptc.Application2.DigitizationDate and Iptc.Application2.DigitizationTime
+//	Iptc.Application2.ExpirationDate and Iptc.Application2.ExpirationTime
+//	Iptc.Application2.ReleaseDate and Iptc.Application2.ReleaseTime
+//	Iptc.Envelope.DateSent and Iptc.Envelope.TimeSent
+//	Exif.Photo.DateTimeOriginal and Exif.Photo.OffsetTimeOriginal
+//	Exif.Photo.DateTimeDigitized and Exif.Photo.OffsetTimeDigitized
+//
+// The result is in the time zone of the time property.  The time property may also hold just a UTC offset (e.g.,
+// "+02:00", as found in the Exif OffsetTime tags), in which case the time of day is taken from the date property.  If
+// the time property is missing, the date property is returned as is (i.e., IPTC dates become midnight UTC).  The keys
+// may belong to different families.
+func DateTime(collection Collection, dateKey, timeKey string) (time.Time, bool, error) {
+	var date, timeOfDay time.Time
+	var dateProperty = getProperty(collection, dateKey)
+	var err error
+	var timeProperty Property
+
+	if dateProperty == nil {
+		return time.Time{}, false, nil
+	}
+
+	if date, err = dateProperty.Time(); err != nil {
+		return time.Time{}, true, err
+	}
+
+	if timeProperty = getProperty(collection, timeKey); timeProperty == nil {
+		return date, true, nil
+	}
+
+	if str, ok := firstString(timeProperty); ok {
+		if offset, err := time.Parse(utcOffsetLayout, strings.TrimSpace(str)); err == nil {
+			return time.Date(date.Year(), date.Month(), date.Day(), date.Hour(), date.Minute(), date.Second(),
+				date.Nanosecond(), offset.Location()), true, nil
+		}
+	}
+
+	if timeOfDay, err = timeProperty.Time(); err != nil {
+		return time.Time{}, true, err
+	}
+
+	return time.Date(date.Year(), date.Month(), date.Day(), timeOfDay.Hour(), timeOfDay.Minute(), timeOfDay.Second(),
+		timeOfDay.Nanosecond(), timeOfDay.Location()), true, nil
+}
+
+// Get returns the value of the metadata property with the given key converted to T, and whether the property exists.
+// If T is a slice, all values of the property are converted, otherwise only the first one is.  Values are converted
+// the same way Unmarshal() converts them, e.g.:
+//
+//	var fNumber, ok, err = metadata.Get[float64](collection.Exif(), "Exif.Photo.FNumber")
+//	var keywords, _, _ = metadata.Get[[]string](collection.IPTC(), "Iptc.Application2.Keywords")
+//	var taken, _, _ = metadata.Get[time.Time](collection.Exif(), "Exif.Photo.DateTimeOriginal")
+//
+// An error is returned if the values can't be converted to T, or if T isn't a slice and the property has no values.
+func Get[T any](properties Properties, key string) (T, bool, error) {
+	var err error
+	var result T
+
+	if !properties.HasKey(key) {
+		return result, false, nil
+	}
+
+	err = convertProperty(properties.Get(key), reflect.ValueOf(&result).Elem())
+
+	return result, true, err
+}
+
+//
+// Private constants
+//
+
+// The layout of a UTC offset (e.g., "+02:00" or "Z").
+const utcOffsetLayout = "Z07:00"
+
+//
+// Private functions
+//
+
+func convertProperty(property Property, target reflect.Value) error {
+	var key = string(property.Family()) + "." + property.GroupName() + "." + property.TagName()
+	var values = getUnmarshalValues(property, &unmarshalTag{}, target)
+
+	if !values.IsValid() || values.Len() == 0 {
+		if target.Kind() == reflect.Slice {
+			target.Set(reflect.MakeSlice(target.Type(), 0, 0))
+
+			return nil
+		}
+
+		return fmt.Errorf("metadata property '%s' has no value", key)
+	}
+
+	if err := storeValues(values, target); err != nil {
+		return fmt.Errorf("cannot convert metadata property '%s' to %s: %v", key, target.Type(), err)
+	}
+
+	return nil
+}
+
+func firstString(property Property) (string, bool) {
+	var values, ok = property.Value().([]string)
+
+	if !ok || len(values) == 0 {
+		return "", false
+	}
+
+	return values[0], true
+}
@@ -0,0 +1,216 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+//
+// Public functions
+//
+
+func TestDateTime(t *testing.T) {
+	var collection = newConvertTestCollection()
+	var tests = []struct {
+		dateKey string
+		name    string
+		timeKey string
+		want    time.Time
+		wantErr bool
+		wantOK  bool
+	}{
+		{
+			dateKey: "Iptc.Application2.DateCreated",
+			name:    "IPTC",
+			timeKey: "Iptc.Application2.TimeCreated",
+			want:    time.Date(2020, time.March, 14, 15, 9, 26, 0, time.FixedZone("", 5*3600+30*60)),
+			wantOK:  true,
+		},
+		{
+			dateKey: "Iptc.Application2.DateCreated",
+			name:    "MissingTime",
+			timeKey: "Iptc.Application2.ReleaseTime",
+			want:    time.Date(2020, time.March, 14, 0, 0, 0, 0, time.UTC),
+			wantOK:  true,
+		},
+		{
+			dateKey: "Iptc.Application2.ReleaseDate",
+			name:    "MissingDate",
+			timeKey: "Iptc.Application2.TimeCreated",
+		},
+		{
+			dateKey: "Exif.Photo.DateTimeOriginal",
+			name:    "ExifOffset",
+			timeKey: "Exif.Photo.OffsetTimeOriginal",
+			want:    time.Date(2021, time.July, 4, 12, 30, 45, 0, time.FixedZone("", -4*3600)),
+			wantOK:  true,
+		},
+		{
+			dateKey: "Iptc.Application2.DateCreated",
+			name:    "InvalidTime",
+			timeKey: "Exif.Image.Make",
+			wantErr: true,
+			wantOK:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var result, ok, err = DateTime(collection, test.dateKey, test.timeKey)
+
+			require.Equal(t, test.wantOK, ok)
+
+			if test.wantErr {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.True(t, test.want.Equal(result), "expected %v, got %v", test.want, result)
+
+			if test.wantOK {
+				var _, wantOffset = test.want.Zone()
+				var _, offset = result.Zone()
+
+				require.Equal(t, wantOffset, offset)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	var collection = newConvertTestCollection()
+	var fNumber, ok, err = Get[float64](collection.Exif(), "Exif.Photo.FNumber")
+	var keywords []string
+	var model string
+
+	require.True(t, ok)
+	require.NoError(t, err)
+	require.Equal(t, 2.8, fNumber)
+
+	keywords, ok, err = Get[[]string](collection.IPTC(), "Iptc.Application2.Keywords")
+
+	require.True(t, ok)
+	require.NoError(t, err)
+	require.Equal(t, []string{"one", "two"}, keywords)
+
+	model, ok, err = Get[string](collection.Exif(), "Exif.Image.Model")
+
+	require.False(t, ok)
+	require.NoError(t, err)
+	require.Empty(t, model)
+
+	_, ok, err = Get[int](collection.Exif(), "Exif.Image.Make")
+
+	require.True(t, ok)
+	require.Error(t, err)
+}
+
+func TestPropertyConversions(t *testing.T) {
+	var collection = newConvertTestCollection()
+	var err error
+	var exposureTime = collection.Exif().Get("Exif.Photo.ExposureTime")
+	var floats, _ = exposureTime.Float64s()
+	var ints []int64
+	var strs []string
+	var taken time.Time
+
+	require.Equal(t, []float64{0.004, 3}, floats)
+
+	// 1/250 isn't an integer.
+
+	_, err = exposureTime.Int64s()
+
+	require.Error(t, err)
+
+	ints, err = collection.Exif().Get("Exif.Image.Orientation").Int64s()
+
+	require.NoError(t, err)
+	require.Equal(t, []int64{6}, ints)
+
+	// Rationals with a denominator of 1 are formatted as integers.
+
+	strs, err = exposureTime.Strings()
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"1/250", "3"}, strs)
+
+	// Undefined values are converted to a single string if they're text, and byte by byte otherwise, just like Get()
+	// converts them.
+
+	for key, expected := range map[string][]string{
+		"Exif.Photo.ComponentsConfiguration": {"1", "2", "3", "0"},
+		"Exif.Photo.ExifVersion":             {"0230"},
+	} {
+		var str string
+
+		strs, err = collection.Exif().Get(key).Strings()
+
+		require.NoError(t, err, key)
+		require.Equal(t, expected, strs, key)
+
+		str, _, err = Get[string](collection.Exif(), key)
+
+		require.NoError(t, err, key)
+		require.Equal(t, expected[0], str, key)
+	}
+
+	taken, err = collection.Exif().Get("Exif.Photo.DateTimeOriginal").Time()
+
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2021, time.July, 4, 12, 30, 45, 0, time.UTC), taken)
+}
+
+//
+// Private functions
+//
+
+func newConvertTestCollection() *collectionImpl {
+	var collection = newCollection()
+
+	for _, property := range []struct {
+		family     Family
+		groupName  string
+		properties *propertiesImpl
+		tagName    string
+		typeID     types.ID
+		value      interface{}
+	}{
+		{FamilyExif, "Image", collection.exifProperties, "Make", types.IDAsciiString, []string{"Canon"}},
+		{FamilyExif, "Image", collection.exifProperties, "Orientation", types.IDUnsignedShort, []uint16{6}},
+		{FamilyExif, "Photo", collection.exifProperties, "ComponentsConfiguration", types.IDUndefined,
+			[]byte{1, 2, 3, 0}},
+		{FamilyExif, "Photo", collection.exifProperties, "DateTimeOriginal", types.IDAsciiString,
+			[]string{"2021:07:04 12:30:45"}},
+		{FamilyExif, "Photo", collection.exifProperties, "ExifVersion", types.IDUndefined, []byte("0230")},
+		{FamilyExif, "Photo", collection.exifProperties, "ExposureTime", types.IDUnsignedRational,
+			[]*big.Rat{big.NewRat(1, 250), big.NewRat(3, 1)}},
+		{FamilyExif, "Photo", collection.exifProperties, "FNumber", types.IDUnsignedRational,
+			[]*big.Rat{big.NewRat(28, 10)}},
+		{FamilyExif, "Photo", collection.exifProperties, "OffsetTimeOriginal", types.IDAsciiString,
+			[]string{"-04:00"}},
+		{FamilyIPTC, "Application2", collection.iptcProperties, "DateCreated", types.IDIPTCDate,
+			[]types.IPTCDate{types.NewIPTCDate(2020, time.March, 14)}},
+		{FamilyIPTC, "Application2", collection.iptcProperties, "Keywords", types.IDIPTCString, []string{"one", "two"}},
+		{FamilyIPTC, "Application2", collection.iptcProperties, "TimeCreated", types.IDIPTCTime,
+			[]types.IPTCTime{types.NewIPTCTime(15, 9, 26, 5, 30)}},
+	} {
+		var result = newProperty(property.family, property.groupName, property.tagName, property.typeID, "", "",
+			false)
+
+		result.value = property.value
+
+		property.properties.add(result)
+	}
+
+	collection.exifProperties.finish()
+	collection.iptcProperties.finish()
+
+	return collection
+}
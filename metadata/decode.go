@@ -0,0 +1,499 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+// The bridge between Exiv2 and Go serializes all metadata properties into a single buffer, which is decoded here in a
+// single pass.  The buffer has the following format:
+//
+//   header:   version (u8), Exif byte order (u8, an Exiv2::ByteOrder value)
+//   property: family (u8), group name (str), tag name (str), tag number (u16), label (str), interpreted value (str),
+//             type ID (u32), flags (u8), value count (u32), length of values (u32), values
+//
+// where str is a u32 length followed by that many bytes, and the header is followed by any number of properties.
+// Values are encoded according to their type ID: strings are a sequence of str, IPTC dates are three i32 values (year,
+// month, day), IPTC times are five i32 values (hour, minute, second, timezone hour and minute offsets), XMP language
+// alternatives are a sequence of str pairs (language, value) and everything else is copied verbatim from the image
+// using the Exif byte order.  All other integers are little endian.  Property flags indicate whether an IPTC dataset is
+// repeatable, whether the interpreted value was deferred (in which case it is empty; see Lazy()) and whether the
+// property was only included because it's needed to decode other properties (see OnlyKeys()).
+//
+// Properties are followed by any number of Exiv2 log messages, which start with 0xFF instead of a family:
+//
+//   log message: 0xFF (u8), level (u8, an Exiv2::LogMsg::Level value), message (str)
+
+//
+// Private types
+//
+
+// bufferReader reads values from a serialized metadata buffer.  Errors are sticky: once a read fails, all subsequent
+// reads return zero values and the error can be checked at a convenient time.
+type bufferReader struct {
+	data   []byte
+	err    error
+	offset int
+}
+
+func (reader *bufferReader) readBytes(length int) []byte {
+	var result []byte
+
+	if reader.err != nil {
+		return nil
+	}
+
+	if length < 0 || reader.remaining() < length {
+		reader.err = fmt.Errorf("metadata buffer truncated at offset %d: expected %d bytes, found %d bytes",
+			reader.offset, length, reader.remaining())
+
+		return nil
+	}
+
+	result = reader.data[reader.offset : reader.offset+length]
+
+	reader.offset += length
+
+	return result
+}
+
+func (reader *bufferReader) peekUInt8() uint8 {
+	if reader.err != nil || reader.remaining() < 1 {
+		return 0
+	}
+
+	return reader.data[reader.offset]
+}
+
+func (reader *bufferReader) readInt32() int32 {
+	return int32(reader.readUInt32())
+}
+
+func (reader *bufferReader) readString() string {
+	return string(reader.readBytes(int(reader.readUInt32())))
+}
+
+func (reader *bufferReader) readUInt8() uint8 {
+	var data = reader.readBytes(1)
+
+	if data == nil {
+		return 0
+	}
+
+	return data[0]
+}
+
+func (reader *bufferReader) readUInt16() uint16 {
+	var data = reader.readBytes(2)
+
+	if data == nil {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint16(data)
+}
+
+func (reader *bufferReader) readUInt32() uint32 {
+	var data = reader.readBytes(4)
+
+	if data == nil {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint32(data)
+}
+
+func (reader *bufferReader) readUInt64() uint64 {
+	var data = reader.readBytes(8)
+
+	if data == nil {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint64(data)
+}
+
+func (reader *bufferReader) remaining() int {
+	return len(reader.data) - reader.offset
+}
+
+//
+// Private constants
+//
+
+// These must match the constants defined in exiv2.h.
+const (
+	bufferVersion = 2
+
+	familyCodeExif = 0
+	familyCodeIPTC = 1
+	familyCodeXMP  = 2
+
+	recordCodeLogMessage = 0xFF
+
+	propertyFlagRepeatable               = 0x01
+	propertyFlagDeferredInterpretedValue = 0x02
+	propertyFlagUnselected               = 0x04
+)
+
+// Values of Exiv2::ByteOrder.
+const (
+	byteOrderInvalid      = 0
+	byteOrderLittleEndian = 1
+	byteOrderBigEndian    = 2
+)
+
+//
+// Private functions
+//
+
+func decodeCollection(buffer []byte, options *readOptions) (*collectionImpl, error) {
+	var collection = newCollection()
+	var reader = newBufferReader(buffer)
+
+	if version := reader.readUInt8(); reader.err == nil && version != bufferVersion {
+		return nil, fmt.Errorf("unsupported metadata buffer version %d", version)
+	}
+
+	// Exiv2 reports an invalid byte order when an image contains no Exif metadata, in which case the byte order
+	// doesn't matter.  Exif metadata in the wild is overwhelmingly little endian, so we'll default to that.
+
+	if reader.readUInt8() == byteOrderBigEndian {
+		collection.exifProperties.byteOrder = binary.BigEndian
+	}
+
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	for reader.remaining() > 0 {
+		var err error
+		var property *propertyImpl
+
+		if reader.peekUInt8() == recordCodeLogMessage {
+			var warning Warning
+
+			reader.readUInt8()
+
+			warning.Severity = Severity(reader.readUInt8())
+			warning.Message = strings.TrimSpace(reader.readString())
+
+			if reader.err != nil {
+				return nil, reader.err
+			}
+
+			collection.warnings = append(collection.warnings, warning)
+
+			continue
+		}
+
+		if property, err = decodeProperty(reader, collection.exifProperties.byteOrder, options); err != nil {
+			return nil, err
+		}
+
+		switch property.family {
+		case FamilyExif:
+			collection.exifProperties.add(property)
+
+		case FamilyIPTC:
+			collection.iptcProperties.add(property)
+
+		case FamilyXMP:
+			applyXMPPropertyDefinition(options.ctx, property, options.logger)
+
+			collection.xmpProperties.add(property)
+		}
+	}
+
+	collection.iptcProperties.decodeIPTCStrings(options.ctx, options.logger)
+	collection.iptcProperties.removeUnselected()
+
+	collection.exifProperties.finish()
+	collection.iptcProperties.finish()
+	collection.xmpProperties.finish()
+
+	return collection, nil
+}
+
+func decodeNumericValues(typeId types.ID, data []byte, byteOrder binary.ByteOrder) interface{} {
+	switch typeId {
+	case types.IDSignedByte:
+		var slice = make([]int8, len(data))
+
+		for i, value := range data {
+			slice[i] = int8(value)
+		}
+
+		return slice
+
+	case types.IDSignedLong:
+		var slice = make([]int32, len(data)/4)
+
+		for i := range slice {
+			slice[i] = int32(byteOrder.Uint32(data[i*4:]))
+		}
+
+		return slice
+
+	case types.IDSignedShort:
+		var slice = make([]int16, len(data)/2)
+
+		for i := range slice {
+			slice[i] = int16(byteOrder.Uint16(data[i*2:]))
+		}
+
+		return slice
+
+	case types.IDSignedRational, types.IDUnsignedRational:
+		var slice = make([]*big.Rat, len(data)/8)
+
+		for i := range slice {
+			var denominator, numerator int64
+
+			if typeId == types.IDSignedRational {
+				numerator = int64(int32(byteOrder.Uint32(data[i*8:])))
+				denominator = int64(int32(byteOrder.Uint32(data[(i*8)+4:])))
+			} else {
+				numerator = int64(byteOrder.Uint32(data[i*8:]))
+				denominator = int64(byteOrder.Uint32(data[(i*8)+4:]))
+			}
+
+			// Plenty of cameras write 0/0 to indicate an unknown value, which big.Rat can't represent (and would panic
+			// on), so we'll treat those as zero.
+
+			if denominator == 0 {
+				slice[i] = new(big.Rat)
+			} else {
+				slice[i] = big.NewRat(numerator, denominator)
+			}
+		}
+
+		return slice
+
+	case types.IDTIFFDouble:
+		var slice = make([]float64, len(data)/8)
+
+		for i := range slice {
+			slice[i] = math.Float64frombits(byteOrder.Uint64(data[i*8:]))
+		}
+
+		return slice
+
+	case types.IDTIFFFloat:
+		var slice = make([]float32, len(data)/4)
+
+		for i := range slice {
+			slice[i] = math.Float32frombits(byteOrder.Uint32(data[i*4:]))
+		}
+
+		return slice
+
+	case types.IDUndefined, types.IDUnsignedByte:
+		var slice = make([]byte, len(data))
+
+		copy(slice, data)
+
+		return slice
+
+	case types.IDUnsignedLong:
+		var slice = make([]uint32, len(data)/4)
+
+		for i := range slice {
+			slice[i] = byteOrder.Uint32(data[i*4:])
+		}
+
+		return slice
+
+	case types.IDUnsignedShort:
+		var slice = make([]uint16, len(data)/2)
+
+		for i := range slice {
+			slice[i] = byteOrder.Uint16(data[i*2:])
+		}
+
+		return slice
+	}
+
+	// Types we don't know about (e.g., Exiv2's TIFF IFD type) are ignored.
+
+	return nil
+}
+
+func decodeProperty(reader *bufferReader, byteOrder binary.ByteOrder, options *readOptions) (*propertyImpl, error) {
+	var count int
+	var family Family
+	var flags uint8
+	var groupName, interpretedValue, label, tagName string
+	var property *propertyImpl
+	var tagNumber uint16
+	var typeId types.ID
+	var values []byte
+
+	switch reader.readUInt8() {
+	case familyCodeExif:
+		family = FamilyExif
+
+	case familyCodeIPTC:
+		family = FamilyIPTC
+
+	case familyCodeXMP:
+		family = FamilyXMP
+
+	default:
+		if reader.err == nil {
+			return nil, fmt.Errorf("unknown metadata family encountered at offset %d", reader.offset-1)
+		}
+	}
+
+	groupName = reader.readString()
+	tagName = reader.readString()
+	tagNumber = reader.readUInt16()
+	label = reader.readString()
+	interpretedValue = reader.readString()
+	typeId = types.ID(reader.readUInt32())
+	flags = reader.readUInt8()
+	count = int(reader.readUInt32())
+	values = reader.readBytes(int(reader.readUInt32()))
+
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	property = newProperty(family, groupName, tagName, typeId, label, interpretedValue,
+		flags&propertyFlagRepeatable != 0)
+	property.tagNumber = tagNumber
+	property.unselected = flags&propertyFlagUnselected != 0
+
+	// IPTC values are always decoded up front: there are few of them, and we need all of them in order to detect the
+	// character set and merge repeatable datasets.
+
+	if options.lazy && family != FamilyIPTC {
+		property.byteOrder = byteOrder
+		property.count = count
+		property.deferredInterpretedValue = flags&propertyFlagDeferredInterpretedValue != 0
+		property.deferredValue = true
+		property.encodedValues = values
+		property.logContext = options.ctx
+		property.logger = options.logger
+	} else if err := decodeValues(property, count, values, byteOrder); err != nil {
+		return nil, fmt.Errorf("could not decode values of metadata property '%s': %w", property.key(), err)
+	}
+
+	if options.logger.Enabled(options.ctx, slog.LevelDebug) {
+		options.logger.DebugContext(options.ctx, "property decoded",
+			"interpretedValue", property.interpretedValue,
+			"label", property.label,
+			"name", property.key(),
+			"numValues", count,
+			"repeatable", property.repeatable,
+			"typeId", property.typeId,
+			"value", property.value,
+		)
+	}
+
+	return property, nil
+}
+
+func decodeValues(property *propertyImpl, count int, data []byte, byteOrder binary.ByteOrder) error {
+	var minimumSize int
+	var reader = newBufferReader(data)
+
+	// The number of non-numeric values is taken from the buffer, so make sure the values could actually fit in the
+	// remaining data before allocating them.  This keeps a corrupt buffer (e.g. one read from an isolated worker) from
+	// causing a huge allocation.  Numeric values are always sized by the data itself.
+
+	switch property.typeId {
+	case types.IDAsciiString, types.IDComment, types.IDIPTCString, types.IDXMPAlt, types.IDXMPBag, types.IDXMPSeq,
+		types.IDXMPText:
+		minimumSize = 4
+
+	case types.IDIPTCDate:
+		minimumSize = 12
+
+	case types.IDIPTCTime:
+		minimumSize = 20
+
+	case types.IDXMPLangAlt:
+		minimumSize = 8
+	}
+
+	if minimumSize > 0 && reader.remaining()/minimumSize < count {
+		return fmt.Errorf("%w: %d values require at least %d bytes but only %d bytes remain", ErrCorrupt, count,
+			count*minimumSize, reader.remaining())
+	}
+
+	switch property.typeId {
+	case types.IDAsciiString, types.IDComment, types.IDXMPAlt, types.IDXMPBag, types.IDXMPSeq, types.IDXMPText:
+		var slice = make([]string, count)
+
+		for i := range slice {
+			slice[i] = reader.readString()
+		}
+
+		property.value = slice
+
+	case types.IDIPTCDate:
+		var slice = make([]types.IPTCDate, count)
+
+		for i := range slice {
+			slice[i] = types.NewIPTCDate(int(reader.readInt32()), time.Month(reader.readInt32()),
+				int(reader.readInt32()))
+		}
+
+		property.value = slice
+
+	// IPTC strings are kept as raw bytes until all datasets have been read, since we can't convert them to UTF-8 until
+	// we know which character set is in use (see decodeIPTCStrings()).
+
+	case types.IDIPTCString:
+		var slice = make([][]byte, count)
+
+		for i := range slice {
+			slice[i] = append([]byte(nil), reader.readBytes(int(reader.readUInt32()))...)
+		}
+
+		property.rawBytes = slice
+
+	case types.IDIPTCTime:
+		var slice = make([]types.IPTCTime, count)
+
+		for i := range slice {
+			slice[i] = types.NewIPTCTime(int(reader.readInt32()), int(reader.readInt32()), int(reader.readInt32()),
+				int(reader.readInt32()), int(reader.readInt32()))
+		}
+
+		property.value = slice
+
+	// XMPLangAlt is a special case, there's really only a single "value", which is a map.
+
+	case types.IDXMPLangAlt:
+		var langAlt = make(map[string]string, count)
+
+		for i := 0; i < count; i++ {
+			var language = reader.readString()
+
+			langAlt[language] = reader.readString()
+		}
+
+		// TODO: can this be done as a single value instead of a slice?
+		property.value = []map[string]string{langAlt}
+
+	default:
+		property.value = decodeNumericValues(property.typeId, data, byteOrder)
+	}
+
+	return reader.err
+}
+
+func newBufferReader(data []byte) *bufferReader {
+	return &bufferReader{
+		data: data,
+	}
+}
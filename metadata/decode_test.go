@@ -0,0 +1,285 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+//
+// Public functions
+//
+
+func TestDecodeCollection(t *testing.T) {
+	var collection, err = decodeCollection(newTestBuffer().data, newReadOptions(nil))
+
+	require.NoError(t, err)
+	require.Equal(t, binary.BigEndian, collection.Exif().ByteOrder())
+	require.Equal(t, []string{"Exif.Image.BitsPerSample", "Exif.Image.Make", "Exif.Photo.FNumber"},
+		collection.Exif().Keys())
+	require.Equal(t, []uint16{8, 8, 8}, collection.Exif().Get("Exif.Image.BitsPerSample").Value())
+	require.Equal(t, []string{"Canon"}, collection.Exif().Get("Exif.Image.Make").Value())
+	require.Equal(t, []*big.Rat{big.NewRat(28, 10)}, collection.Exif().Get("Exif.Photo.FNumber").Value())
+	require.Equal(t, "F2.8", collection.Exif().Get("Exif.Photo.FNumber").InterpretedValue())
+	require.Equal(t, []string{"Iptc.Application2.DateCreated", "Iptc.Application2.Keywords",
+		"Iptc.Envelope.CharacterSet"}, collection.IPTC().Keys())
+	require.Equal(t, []string{"café", "two"}, collection.IPTC().Get("Iptc.Application2.Keywords").Value())
+	require.Equal(t, []types.IPTCDate{types.NewIPTCDate(2020, time.March, 14)},
+		collection.IPTC().Get("Iptc.Application2.DateCreated").Value())
+	require.Equal(t, []map[string]string{{"x-default": "Title", "de": "Titel"}},
+		collection.XMP().Get("Xmp.dc.title").Value())
+	require.Equal(t, []string{"one", "two"}, collection.XMP().Get("Xmp.dc.subject").Value())
+}
+
+func TestDecodeCollectionLazy(t *testing.T) {
+	var eager, err = decodeCollection(newTestBuffer().data, newReadOptions(nil))
+	var lazy *collectionImpl
+
+	require.NoError(t, err)
+
+	lazy, err = decodeCollection(newTestBuffer().data, newReadOptions([]ReadOption{Lazy()}))
+
+	require.NoError(t, err)
+
+	// Values are only decoded when they're first accessed.
+
+	require.True(t, lazy.exifProperties.propertyMap["Exif.Photo.FNumber"].deferredValue)
+	require.True(t, lazy.xmpProperties.propertyMap["Xmp.dc.title"].deferredValue)
+
+	require.True(t, Diff(eager, lazy).Empty())
+	require.Equal(t, "F2.8", lazy.Exif().Get("Exif.Photo.FNumber").InterpretedValue())
+}
+
+func TestDecodeCollectionOversizedCount(t *testing.T) {
+	for _, test := range []struct {
+		family uint8
+		typeId types.ID
+		values func(values *testBuffer)
+	}{
+		{family: familyCodeExif, typeId: types.IDAsciiString, values: func(values *testBuffer) {
+			values.string("Canon")
+		}},
+		{family: familyCodeIPTC, typeId: types.IDIPTCDate, values: func(values *testBuffer) {
+			values.uint32(2020)
+			values.uint32(uint32(time.March))
+			values.uint32(14)
+		}},
+		{family: familyCodeIPTC, typeId: types.IDIPTCString, values: func(values *testBuffer) {
+			values.string("two")
+		}},
+		{family: familyCodeIPTC, typeId: types.IDIPTCTime, values: func(values *testBuffer) {
+			for i := 0; i < 5; i++ {
+				values.uint32(0)
+			}
+		}},
+		{family: familyCodeXMP, typeId: types.IDXMPLangAlt, values: func(values *testBuffer) {
+			values.string("x-default")
+			values.string("Title")
+		}},
+	} {
+		t.Run(test.typeId.String(), func(t *testing.T) {
+			var buffer = &testBuffer{}
+			var err error
+
+			buffer.uint8(bufferVersion)
+			buffer.uint8(byteOrderLittleEndian)
+			buffer.property(test.family, "Group", "Tag", 1, "", test.typeId, 0, 0xFFFFFFFF, test.values)
+
+			_, err = decodeCollection(buffer.data, newReadOptions(nil))
+
+			require.True(t, errors.Is(err, ErrCorrupt), "unexpected error: %v", err)
+		})
+	}
+}
+
+func TestDecodeCollectionTruncated(t *testing.T) {
+	var buffer = newTestBuffer().data
+	var err error
+
+	for length := 1; length < len(buffer); length++ {
+		_, err = decodeCollection(buffer[:length], newReadOptions(nil))
+
+		// Some prefixes end exactly after a property, which is fine.
+
+		if err == nil {
+			continue
+		}
+
+		require.Contains(t, err.Error(), "truncated", "length %d", length)
+	}
+
+	_, err = decodeCollection(append([]byte{bufferVersion + 1}, buffer[1:]...), newReadOptions(nil))
+
+	require.Error(t, err)
+}
+
+// TestDecodeCollectionUnselected checks that properties only included to decode other properties (see OnlyKeys()) are
+// used and then dropped.
+func TestDecodeCollectionUnselected(t *testing.T) {
+	var buffer = &testBuffer{}
+	var collection *collectionImpl
+	var err error
+
+	buffer.uint8(bufferVersion)
+	buffer.uint8(byteOrderLittleEndian)
+
+	buffer.property(familyCodeIPTC, "Envelope", "CharacterSet", 90, "\x1b-A", types.IDIPTCString,
+		propertyFlagUnselected, 1, func(values *testBuffer) {
+			values.string("\x1b-A")
+		})
+	buffer.property(familyCodeIPTC, "Application2", "Keywords", 25, "caf\xe9", types.IDIPTCString,
+		propertyFlagRepeatable, 1, func(values *testBuffer) {
+			values.string("caf\xe9")
+		})
+
+	collection, err = decodeCollection(buffer.data, newReadOptions(nil))
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"Iptc.Application2.Keywords"}, collection.IPTC().Keys())
+	require.Equal(t, []string{"Iptc.Application2.Keywords"}, collection.IPTC().KeysInFileOrder())
+	require.Equal(t, []string{"café"}, collection.IPTC().Get("Iptc.Application2.Keywords").Value())
+}
+
+func TestDecodeCollectionWarnings(t *testing.T) {
+	var buffer = newTestBuffer()
+	var collection *collectionImpl
+	var err error
+
+	// Exiv2 terminates its messages with a newline.
+
+	buffer.logMessage(uint8(SeverityWarning), "Directory Canon has an unexpected next pointer\n")
+	buffer.logMessage(uint8(SeverityError), "XMP Toolkit error 201")
+
+	collection, err = decodeCollection(buffer.data, newReadOptions(nil))
+
+	require.NoError(t, err)
+	require.Equal(t, []Warning{
+		{Message: "Directory Canon has an unexpected next pointer", Severity: SeverityWarning},
+		{Message: "XMP Toolkit error 201", Severity: SeverityError},
+	}, collection.Warnings())
+	require.Len(t, collection.Exif().Keys(), 3)
+
+	// A message that is cut short makes the buffer invalid, like a property would.
+
+	_, err = decodeCollection(buffer.data[:len(buffer.data)-1], newReadOptions(nil))
+
+	require.Error(t, err)
+}
+
+//
+// Private types
+//
+
+// testBuffer builds a serialized metadata buffer, as produced by the bridge between Exiv2 and Go.
+type testBuffer struct {
+	data []byte
+}
+
+func (buffer *testBuffer) logMessage(severity uint8, message string) {
+	buffer.uint8(recordCodeLogMessage)
+	buffer.uint8(severity)
+	buffer.string(message)
+}
+
+func (buffer *testBuffer) property(familyCode uint8, groupName, tagName string, tagNumber uint16,
+	interpretedValue string, typeId types.ID, flags uint8, count int, values func(values *testBuffer)) {
+	var encodedValues = &testBuffer{}
+
+	values(encodedValues)
+
+	buffer.uint8(familyCode)
+	buffer.string(groupName)
+	buffer.string(tagName)
+	buffer.data = binary.LittleEndian.AppendUint16(buffer.data, tagNumber)
+	buffer.string(tagName)
+	buffer.string(interpretedValue)
+	buffer.uint32(uint32(typeId))
+	buffer.uint8(flags)
+	buffer.uint32(uint32(count))
+	buffer.uint32(uint32(len(encodedValues.data)))
+
+	buffer.data = append(buffer.data, encodedValues.data...)
+}
+
+func (buffer *testBuffer) string(str string) {
+	buffer.uint32(uint32(len(str)))
+
+	buffer.data = append(buffer.data, str...)
+}
+
+func (buffer *testBuffer) uint8(value uint8) {
+	buffer.data = append(buffer.data, value)
+}
+
+func (buffer *testBuffer) uint32(value uint32) {
+	buffer.data = binary.LittleEndian.AppendUint32(buffer.data, value)
+}
+
+//
+// Private functions
+//
+
+// newTestBuffer creates a buffer holding a few properties of each family, using big endian Exif values.
+func newTestBuffer() *testBuffer {
+	var buffer = &testBuffer{}
+
+	buffer.uint8(bufferVersion)
+	buffer.uint8(byteOrderBigEndian)
+
+	buffer.property(familyCodeExif, "Image", "Make", 0x010f, "Canon", types.IDAsciiString, 0, 1,
+		func(values *testBuffer) {
+			values.string("Canon")
+		})
+	buffer.property(familyCodeExif, "Photo", "FNumber", 0x829d, "F2.8", types.IDUnsignedRational, 0, 1,
+		func(values *testBuffer) {
+			values.data = binary.BigEndian.AppendUint32(values.data, 28)
+			values.data = binary.BigEndian.AppendUint32(values.data, 10)
+		})
+	buffer.property(familyCodeExif, "Image", "BitsPerSample", 0x0102, "8 8 8", types.IDUnsignedShort, 0, 3,
+		func(values *testBuffer) {
+			for i := 0; i < 3; i++ {
+				values.data = binary.BigEndian.AppendUint16(values.data, 8)
+			}
+		})
+
+	// Keywords are encoded using ISO 8859-1 until the character set is known.
+
+	buffer.property(familyCodeIPTC, "Envelope", "CharacterSet", 90, "\x1b-A", types.IDIPTCString, 0, 1,
+		func(values *testBuffer) {
+			values.string("\x1b-A")
+		})
+	buffer.property(familyCodeIPTC, "Application2", "Keywords", 25, "caf\xe9", types.IDIPTCString,
+		propertyFlagRepeatable, 1, func(values *testBuffer) {
+			values.string("caf\xe9")
+		})
+	buffer.property(familyCodeIPTC, "Application2", "Keywords", 25, "two", types.IDIPTCString,
+		propertyFlagRepeatable, 1, func(values *testBuffer) {
+			values.string("two")
+		})
+	buffer.property(familyCodeIPTC, "Application2", "DateCreated", 55, "2020-03-14", types.IDIPTCDate, 0, 1,
+		func(values *testBuffer) {
+			values.uint32(2020)
+			values.uint32(uint32(time.March))
+			values.uint32(14)
+		})
+
+	buffer.property(familyCodeXMP, "dc", "title", 0, `lang="x-default" Title, lang="de" Titel`, types.IDXMPLangAlt, 0,
+		2, func(values *testBuffer) {
+			values.string("x-default")
+			values.string("Title")
+			values.string("de")
+			values.string("Titel")
+		})
+	buffer.property(familyCodeXMP, "dc", "subject", 0, "one, two", types.IDXMPBag, 0, 2, func(values *testBuffer) {
+		values.string("one")
+		values.string("two")
+	})
+
+	return buffer
+}
@@ -0,0 +1,216 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+)
+
+//
+// Public types
+//
+
+// CollectionDiff describes the differences between two collections, as reported by Diff().  Properties are listed
+// by family (Exif, then IPTC, then XMP) and then in key order.
+type CollectionDiff struct {
+	// Added holds the properties found only in the second collection.
+	Added []Property
+
+	// Changed holds the properties found in both collections whose type or values differ.
+	Changed []PropertyChange
+
+	// Removed holds the properties found only in the first collection.
+	Removed []Property
+}
+
+// Empty reports whether the collections are identical.
+func (diff *CollectionDiff) Empty() bool {
+	return len(diff.Added) == 0 && len(diff.Changed) == 0 && len(diff.Removed) == 0
+}
+
+// DiffOption is used to configure how collections are compared by Diff().
+type DiffOption func(options *diffOptions)
+
+// PropertyChange describes a property whose type or values differ between two collections.
+type PropertyChange struct {
+	// Key is the key of the property.
+	Key string
+
+	// New is the property in the second collection.
+	New Property
+
+	// Old is the property in the first collection.
+	Old Property
+}
+
+//
+// Public functions
+//
+
+// Diff compares two collections, reporting the properties that were added, removed or changed in the second one.  A
+// property is considered changed if its type ID or its values differ; interpreted values are not compared since
+// they're derived from the values.  Values are compared by what they represent rather than how they're stored, so
+// rationals are compared numerically (e.g., 2/4 equals 1/2), NaN equals NaN, IPTC dates and times are compared by
+// their components and the languages of XMP language alternatives may be listed in any order.  A nil collection is
+// treated as an empty one.
+func Diff(a, b Collection, options ...DiffOption) *CollectionDiff {
+	var diffOptions = &diffOptions{}
+	var result = &CollectionDiff{}
+
+	for _, option := range options {
+		option(diffOptions)
+	}
+
+	a = getDiffCollection(a)
+	b = getDiffCollection(b)
+
+	diffProperties(a.Exif(), b.Exif(), diffOptions, result)
+	diffProperties(a.IPTC(), b.IPTC(), diffOptions, result)
+	diffProperties(a.XMP(), b.XMP(), diffOptions, result)
+
+	return result
+}
+
+// IgnoreGroups excludes the properties belonging to the given groups from the comparison.  Groups are given as a
+// family and group name (e.g., "Exif.Thumbnail" or "Xmp.xmpMM").
+func IgnoreGroups(groups ...string) DiffOption {
+	return func(options *diffOptions) {
+		for _, group := range groups {
+			options.ignoredPatterns = append(options.ignoredPatterns, group+".*")
+		}
+	}
+}
+
+// IgnoreKeys excludes the properties whose keys match any of the given patterns from the comparison.  Patterns are
+// the same as those accepted by Properties.Match() (e.g., "Exif.Image.DateTime" or "Xmp.*.ModifyDate").
+func IgnoreKeys(patterns ...string) DiffOption {
+	return func(options *diffOptions) {
+		options.ignoredPatterns = append(options.ignoredPatterns, patterns...)
+	}
+}
+
+//
+// Private types
+//
+
+type diffOptions struct {
+	ignoredPatterns []string
+}
+
+func (options *diffOptions) isIgnored(key string) bool {
+	for _, pattern := range options.ignoredPatterns {
+		if matchPattern(pattern, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//
+// Private functions
+//
+
+func diffProperties(a, b Properties, options *diffOptions, result *CollectionDiff) {
+	for _, key := range a.Keys() {
+		if options.isIgnored(key) {
+			continue
+		}
+
+		if !b.HasKey(key) {
+			result.Removed = append(result.Removed, a.Get(key))
+
+			continue
+		}
+
+		if !propertiesEqual(a.Get(key), b.Get(key)) {
+			result.Changed = append(result.Changed, PropertyChange{
+				Key: key,
+				New: b.Get(key),
+				Old: a.Get(key),
+			})
+		}
+	}
+
+	for _, key := range b.Keys() {
+		if !options.isIgnored(key) && !a.HasKey(key) {
+			result.Added = append(result.Added, b.Get(key))
+		}
+	}
+}
+
+func getDiffCollection(collection Collection) Collection {
+	if collection == nil {
+		return newCollection()
+	}
+
+	return collection
+}
+
+func propertiesEqual(a, b Property) bool {
+	var valueA = reflect.ValueOf(a.Value())
+	var valueB = reflect.ValueOf(b.Value())
+
+	if a.TypeID() != b.TypeID() {
+		return false
+	}
+
+	if !valueA.IsValid() || !valueB.IsValid() {
+		return valueA.IsValid() == valueB.IsValid()
+	}
+
+	if valueA.Type() != valueB.Type() {
+		return false
+	}
+
+	if valueA.Kind() != reflect.Slice {
+		return reflect.DeepEqual(valueA.Interface(), valueB.Interface())
+	}
+
+	if valueA.Len() != valueB.Len() {
+		return false
+	}
+
+	for i := 0; i < valueA.Len(); i++ {
+		if !valuesEqual(valueA.Index(i).Interface(), valueB.Index(i).Interface()) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func valuesEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case *big.Rat:
+		var b, _ = b.(*big.Rat)
+
+		if a == nil || b == nil {
+			return a == b
+		}
+
+		return a.Cmp(b) == 0
+
+	case float32:
+		var b, _ = b.(float32)
+
+		return a == b || (math.IsNaN(float64(a)) && math.IsNaN(float64(b)))
+
+	case float64:
+		var b, _ = b.(float64)
+
+		return a == b || (math.IsNaN(a) && math.IsNaN(b))
+
+	// IPTC dates and times.
+
+	case fmt.Stringer:
+		var b, ok = b.(fmt.Stringer)
+
+		return ok && a.String() == b.String()
+	}
+
+	// Maps (i.e., XMP language alternatives) are compared regardless of order.
+
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,187 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+//
+// Public functions
+//
+
+func TestDiff(t *testing.T) {
+	var diff = Diff(
+		newDiffTestCollection(map[string]interface{}{
+			"Exif.Image.Make":  []string{"Canon"},
+			"Exif.Image.Model": []string{"EOS"},
+			"Xmp.dc.subject":   []string{"one"},
+		}),
+		newDiffTestCollection(map[string]interface{}{
+			"Exif.Image.Make":     []string{"Nikon"},
+			"Exif.Image.Software": []string{"Firmware"},
+			"Xmp.dc.subject":      []string{"one"},
+		}))
+
+	require.False(t, diff.Empty())
+	require.Len(t, diff.Added, 1)
+	require.Equal(t, "Software", diff.Added[0].TagName())
+	require.Len(t, diff.Changed, 1)
+	require.Equal(t, "Exif.Image.Make", diff.Changed[0].Key)
+	require.Equal(t, []string{"Canon"}, diff.Changed[0].Old.Value())
+	require.Equal(t, []string{"Nikon"}, diff.Changed[0].New.Value())
+	require.Len(t, diff.Removed, 1)
+	require.Equal(t, "Model", diff.Removed[0].TagName())
+
+	// A nil collection is treated as an empty one.
+
+	diff = Diff(nil, newDiffTestCollection(map[string]interface{}{"Exif.Image.Make": []string{"Canon"}}))
+
+	require.Len(t, diff.Added, 1)
+	require.True(t, Diff(nil, nil).Empty())
+}
+
+func TestDiffIgnore(t *testing.T) {
+	var a = newDiffTestCollection(map[string]interface{}{
+		"Exif.Image.DateTime":  []string{"2020:03:14 15:09:26"},
+		"Exif.Image.Make":      []string{"Canon"},
+		"Exif.Thumbnail.Width": []uint32{160},
+		"Xmp.xmp.ModifyDate":   []string{"2020-03-14T15:09:26"},
+	})
+	var b = newDiffTestCollection(map[string]interface{}{
+		"Exif.Image.DateTime":   []string{"2021:03:14 15:09:26"},
+		"Exif.Image.Make":       []string{"Canon"},
+		"Exif.Thumbnail.Height": []uint32{120},
+		"Xmp.xmp.ModifyDate":    []string{"2021-03-14T15:09:26"},
+	})
+
+	require.False(t, Diff(a, b).Empty())
+	require.False(t, Diff(a, b, IgnoreGroups("Exif.Thumbnail")).Empty())
+	require.False(t, Diff(a, b, IgnoreKeys("Exif.Image.DateTime", "Xmp.*.ModifyDate")).Empty())
+	require.True(t, Diff(a, b, IgnoreGroups("Exif.Thumbnail"), IgnoreKeys("Exif.Image.DateTime",
+		"Xmp.*.ModifyDate")).Empty())
+
+	// Groups are matched as a whole.
+
+	require.False(t, Diff(a, b, IgnoreGroups("Exif.Thumb"), IgnoreKeys("Exif.Image.DateTime",
+		"Xmp.*.ModifyDate")).Empty())
+}
+
+func TestDiffValues(t *testing.T) {
+	var tests = []struct {
+		a     interface{}
+		b     interface{}
+		equal bool
+		name  string
+	}{
+		{
+			a:     []*big.Rat{big.NewRat(1, 2)},
+			b:     []*big.Rat{big.NewRat(2, 4)},
+			equal: true,
+			name:  "RationalsEqual",
+		},
+		{
+			a:    []*big.Rat{big.NewRat(1, 2)},
+			b:    []*big.Rat{big.NewRat(1, 3)},
+			name: "RationalsDiffer",
+		},
+		{
+			a:    []*big.Rat{big.NewRat(1, 2)},
+			b:    []*big.Rat{big.NewRat(1, 2), big.NewRat(1, 2)},
+			name: "LengthsDiffer",
+		},
+		{
+			a:     []float64{math.NaN(), 1.5},
+			b:     []float64{math.NaN(), 1.5},
+			equal: true,
+			name:  "NaN",
+		},
+		{
+			a:     []float32{float32(math.NaN())},
+			b:     []float32{float32(math.NaN())},
+			equal: true,
+			name:  "NaN32",
+		},
+		{
+			a:    []float64{math.NaN()},
+			b:    []float64{1.5},
+			name: "NaNDiffers",
+		},
+		{
+			a:     []map[string]string{{"x-default": "Title", "de": "Titel", "fr": "Titre"}},
+			b:     []map[string]string{{"fr": "Titre", "x-default": "Title", "de": "Titel"}},
+			equal: true,
+			name:  "LangAltEqual",
+		},
+		{
+			a:    []map[string]string{{"x-default": "Title", "de": "Titel"}},
+			b:    []map[string]string{{"x-default": "Title"}},
+			name: "LangAltDiffers",
+		},
+		{
+			a:     []types.IPTCDate{types.NewIPTCDate(2020, time.March, 14)},
+			b:     []types.IPTCDate{types.NewIPTCDate(2020, time.March, 14)},
+			equal: true,
+			name:  "IPTCDate",
+		},
+		{
+			a:    []types.IPTCTime{types.NewIPTCTime(15, 9, 26, 1, 0)},
+			b:    []types.IPTCTime{types.NewIPTCTime(15, 9, 26, 2, 0)},
+			name: "IPTCTimeDiffers",
+		},
+		{
+			a:    []uint16{1},
+			b:    []uint32{1},
+			name: "TypesDiffer",
+		},
+		{
+			a:    []string{"value"},
+			name: "Nil",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var diff = Diff(newDiffTestCollection(map[string]interface{}{"Exif.Image.Make": test.a}),
+				newDiffTestCollection(map[string]interface{}{"Exif.Image.Make": test.b}))
+
+			require.Equal(t, test.equal, diff.Empty())
+		})
+	}
+}
+
+//
+// Private functions
+//
+
+// newDiffTestCollection creates a collection holding the given values.  The type ID of every property is the same,
+// so that only values are compared.
+func newDiffTestCollection(values map[string]interface{}) Collection {
+	var collection = newCollection()
+
+	for key, value := range values {
+		var parts = strings.SplitN(key, ".", 3)
+		var property = newProperty(Family(parts[0]), parts[1], parts[2], types.IDUndefined, "", "", false)
+
+		property.value = value
+
+		switch property.family {
+		case FamilyExif:
+			collection.exifProperties.add(property)
+
+		case FamilyXMP:
+			collection.xmpProperties.add(property)
+		}
+	}
+
+	collection.exifProperties.finish()
+	collection.xmpProperties.finish()
+
+	return collection
+}
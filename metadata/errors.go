@@ -0,0 +1,225 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//
+// Public types
+//
+
+// Error is an error reported by Exiv2.  Errors that can be classified are matched by one of ErrCorrupt, ErrIO,
+// ErrNotFound or ErrUnsupportedFormat when using errors.Is.
+type Error struct {
+	// Code is the Exiv2 error code (a value of Exiv2::ErrorCode).
+	Code int
+
+	// Message is the error message reported by Exiv2.
+	Message string
+
+	kind error
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s (Exiv2 error code %d)", err.Message, err.Code)
+}
+
+// Unwrap returns the sentinel error matching the kind of the error, or nil if the error couldn't be classified.
+func (err *Error) Unwrap() error {
+	return err.kind
+}
+
+// FieldError describes why the value of a metadata property couldn't be stored in a struct field by Unmarshal.
+type FieldError struct {
+	// Field is the name of the struct field.
+	Field string
+
+	// Key is the key of the metadata property.
+	Key string
+
+	// Err is the conversion error.
+	Err error
+}
+
+func (err *FieldError) Error() string {
+	return fmt.Sprintf("could not store metadata property '%s' in field %s: %v", err.Key, err.Field, err.Err)
+}
+
+// Unwrap returns the conversion error.
+func (err *FieldError) Unwrap() error {
+	return err.Err
+}
+
+// LimitError is returned when reading an image would exceed one of the limits set with the Limits() read option.
+type LimitError struct {
+	// Limit is the name of the ReadLimits field whose limit was exceeded (e.g., "MaxProperties").
+	Limit string
+
+	// Value is the value of the limit that was exceeded.
+	Value int
+
+	code int
+}
+
+func (err *LimitError) Error() string {
+	return fmt.Sprintf("image metadata exceeds read limit %s (%d)", err.Limit, err.Value)
+}
+
+// Unwrap returns ErrLimitExceeded.
+func (err *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// UnmarshalError is returned by Unmarshal when the values of one or more metadata properties couldn't be stored in
+// their struct fields.  All other fields are still filled in.
+type UnmarshalError struct {
+	// Fields contains an error for each field that couldn't be filled in, in the order in which the fields are
+	// declared.
+	Fields []*FieldError
+}
+
+func (err *UnmarshalError) Error() string {
+	var messages = make([]string, len(err.Fields))
+
+	for i, fieldErr := range err.Fields {
+		messages[i] = fieldErr.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns the errors of the individual fields.
+func (err *UnmarshalError) Unwrap() []error {
+	var result = make([]error, len(err.Fields))
+
+	for i, fieldErr := range err.Fields {
+		result[i] = fieldErr
+	}
+
+	return result
+}
+
+// WarningError is returned when reading an image in strict mode (see Strict()) and Exiv2 reports a warning.
+type WarningError struct {
+	// Warning is the first warning reported by Exiv2.
+	Warning Warning
+}
+
+func (err *WarningError) Error() string {
+	return fmt.Sprintf("Exiv2 reported a %s: %s", err.Warning.Severity, err.Warning.Message)
+}
+
+// Unwrap returns ErrWarning.
+func (err *WarningError) Unwrap() error {
+	return ErrWarning
+}
+
+//
+// Public variables
+//
+
+var (
+	// ErrCorrupt indicates that an image or its metadata is malformed.
+	ErrCorrupt = errors.New("corrupt image")
+
+	// ErrIO indicates that an image could not be read.
+	ErrIO = errors.New("image could not be read")
+
+	// ErrLimitExceeded indicates that reading an image would exceed one of the configured read limits (see
+	// LimitError).
+	ErrLimitExceeded = errors.New("read limit exceeded")
+
+	// ErrNotFound indicates that an image file does not exist.
+	ErrNotFound = errors.New("image not found")
+
+	// ErrWarning indicates that Exiv2 reported a warning while reading an image in strict mode (see WarningError).
+	ErrWarning = errors.New("warning reported while reading image")
+
+	// ErrUnsupportedFormat indicates that the format of an image is not recognized or not supported by Exiv2.
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+)
+
+//
+// Private constants
+//
+
+// These must match the constants defined in exiv2.h.
+const (
+	errorKindOther             = 0
+	errorKindCorrupt           = 1
+	errorKindIO                = 2
+	errorKindUnsupportedFormat = 3
+
+	// Exiv2 can't tell when a file doesn't exist (see readBuffer()), but isolated workers need to tell us.
+	errorKindNotFound = 4
+
+	limitCodeProperties        = 1
+	limitCodeValuesPerProperty = 2
+	limitCodeTotalBytes        = 3
+	limitCodeXMPPacketSize     = 4
+)
+
+//
+// Private functions
+//
+
+func getErrorKindCode(kind error) int {
+	switch kind {
+	case ErrCorrupt:
+		return errorKindCorrupt
+
+	case ErrIO:
+		return errorKindIO
+
+	case ErrNotFound:
+		return errorKindNotFound
+
+	case ErrUnsupportedFormat:
+		return errorKindUnsupportedFormat
+	}
+
+	return errorKindOther
+}
+
+func newError(code, kindCode int, message string) *Error {
+	var err = &Error{
+		Code:    code,
+		Message: message,
+	}
+
+	switch kindCode {
+	case errorKindCorrupt:
+		err.kind = ErrCorrupt
+
+	case errorKindIO:
+		err.kind = ErrIO
+
+	case errorKindNotFound:
+		err.kind = ErrNotFound
+
+	case errorKindUnsupportedFormat:
+		err.kind = ErrUnsupportedFormat
+	}
+
+	return err
+}
+
+func newLimitError(code int, limits *ReadLimits) error {
+	switch code {
+	case limitCodeProperties:
+		return &LimitError{Limit: "MaxProperties", Value: limits.MaxProperties, code: code}
+
+	case limitCodeValuesPerProperty:
+		return &LimitError{Limit: "MaxValuesPerProperty", Value: limits.MaxValuesPerProperty, code: code}
+
+	case limitCodeTotalBytes:
+		return &LimitError{Limit: "MaxTotalBytes", Value: limits.MaxTotalBytes, code: code}
+
+	case limitCodeXMPPacketSize:
+		return &LimitError{Limit: "MaxXMPPacketSize", Value: limits.MaxXMPPacketSize, code: code}
+	}
+
+	return fmt.Errorf("unknown read limit %d exceeded", code)
+}
@@ -0,0 +1,76 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+//
+// Public functions
+//
+
+func TestError(t *testing.T) {
+	var err error
+	var exiv2Err *Error
+
+	for kindCode, kind := range map[int]error{
+		errorKindCorrupt:           ErrCorrupt,
+		errorKindIO:                ErrIO,
+		errorKindNotFound:          ErrNotFound,
+		errorKindUnsupportedFormat: ErrUnsupportedFormat,
+	} {
+		err = fmt.Errorf("wrapped: %w", newError(11, kindCode, "message"))
+
+		require.True(t, errors.Is(err, kind))
+		require.True(t, errors.As(err, &exiv2Err))
+		require.Equal(t, 11, exiv2Err.Code)
+		require.Equal(t, "message", exiv2Err.Message)
+		require.Equal(t, kindCode, getErrorKindCode(exiv2Err.kind))
+		require.Equal(t, "message (Exiv2 error code 11)", exiv2Err.Error())
+
+		for _, otherKind := range []error{ErrCorrupt, ErrIO, ErrNotFound, ErrUnsupportedFormat} {
+			if otherKind != kind {
+				require.False(t, errors.Is(err, otherKind))
+			}
+		}
+	}
+
+	// Errors that can't be classified still carry the Exiv2 error code.
+
+	exiv2Err = newError(-1, errorKindOther, "unknown")
+
+	require.Nil(t, exiv2Err.Unwrap())
+	require.Equal(t, -1, exiv2Err.Code)
+	require.Equal(t, errorKindOther, getErrorKindCode(exiv2Err.kind))
+}
+
+func TestLimitError(t *testing.T) {
+	var err error
+	var limitErr *LimitError
+	var limits = &ReadLimits{MaxProperties: 1, MaxTotalBytes: 2, MaxValuesPerProperty: 3, MaxXMPPacketSize: 4}
+
+	for code, expected := range map[int]LimitError{
+		limitCodeProperties:        {Limit: "MaxProperties", Value: 1},
+		limitCodeTotalBytes:        {Limit: "MaxTotalBytes", Value: 2},
+		limitCodeValuesPerProperty: {Limit: "MaxValuesPerProperty", Value: 3},
+		limitCodeXMPPacketSize:     {Limit: "MaxXMPPacketSize", Value: 4},
+	} {
+		err = fmt.Errorf("wrapped: %w", newLimitError(code, limits))
+
+		require.True(t, errors.Is(err, ErrLimitExceeded))
+		require.True(t, errors.As(err, &limitErr))
+		require.Equal(t, expected.Limit, limitErr.Limit)
+		require.Equal(t, expected.Value, limitErr.Value)
+		require.Contains(t, err.Error(), expected.Limit)
+	}
+
+	// Codes unknown to this version of the bridge are still reported, but not as limit errors.
+
+	err = newLimitError(0, limits)
+
+	require.Error(t, err)
+	require.False(t, errors.Is(err, ErrLimitExceeded))
+}
@@ -1,6 +1,7 @@
 #ifndef _EXIV2_BRIDGE_H_
 #define _EXIV2_BRIDGE_H_
 
+#include <stddef.h>
 #include <stdint.h>
 
 #ifdef __cplusplus
@@ -8,55 +9,76 @@ extern "C"
 {
 #endif
 
+// Constant definitions
+
+#define EXIV2_BUFFER_VERSION 2
+
+#define EXIV2_ERROR_KIND_OTHER 0
+#define EXIV2_ERROR_KIND_CORRUPT 1
+#define EXIV2_ERROR_KIND_IO 2
+#define EXIV2_ERROR_KIND_UNSUPPORTED_FORMAT 3
+
+#define EXIV2_FAMILY_EXIF 0
+#define EXIV2_FAMILY_IPTC 1
+#define EXIV2_FAMILY_XMP 2
+
+#define EXIV2_RECORD_LOG_MESSAGE 0xFF
+
+#define EXIV2_LIMIT_PROPERTIES 1
+#define EXIV2_LIMIT_VALUES_PER_PROPERTY 2
+#define EXIV2_LIMIT_TOTAL_BYTES 3
+#define EXIV2_LIMIT_XMP_PACKET_SIZE 4
+
+#define EXIV2_PROPERTY_REPEATABLE 0x01
+#define EXIV2_PROPERTY_DEFERRED_INTERPRETED_VALUE 0x02
+#define EXIV2_PROPERTY_UNSELECTED 0x04
+
 // Struct definitions
 
+typedef struct exiv2Buffer
+{
+     char *data;
+     size_t length;
+} exiv2Buffer;
+
 typedef struct exiv2Error
 {
      int code;
+     int kind;
+     int limit;
      const char *message;
 } exiv2Error;
 
-typedef struct valueHolder
+typedef struct exiv2TagInfo
 {
-     int dayValue;
-     double doubleValue;
-     int hourValue;
-     const char *langValue;
-     long longValue;
-     int minuteValue;
-     int monthValue;
-     char *strValue;
-     uint32_t rationalValueD;
-     uint32_t rationalValueN;
-     int secondValue;
-     int timezoneHourOffset;
-     int timezoneMinuteOffset;
-     int yearValue;
-} valueHolder;
-
-// Function pointer definitions
-
-typedef void (*propertyOnEndCallback)(void*, const char *);
-typedef void (*propertyOnStartCallback)(void*, const char *, const char *, const char *, int, const char *, const char *,
-     int, int);
-typedef void (*valueCallback)(void*, valueHolder*);
-
-// Struct definitions
+     int repeatable;
+     int tag;
+     int typeId;
+     char *label;
+} exiv2TagInfo;
 
-typedef struct readHandler
+typedef struct readOptions
 {
-     propertyOnEndCallback poec;
-     propertyOnStartCallback posc;
-     valueCallback vc;
-} readHandler;
+     int lazy;
+     int lenient;
+     const char **patterns;
+     size_t numPatterns;
+     size_t maxProperties;
+     size_t maxValuesPerProperty;
+     size_t maxTotalBytes;
+     size_t maxXMPPacketSize;
+} readOptions;
 
 // Function definitions
 
-void onPropertyEnd(void*, const char*);
-void onPropertyStart(void*, const char*, const char*, const char*, int, const char *, const char *, int, int);
-void onValue(void*, valueHolder*);
-void readCollectionFromFile (const char*, exiv2Error*, valueHolder*, readHandler*, void*);
-void readCollectionFromURL (const char*, exiv2Error*, valueHolder*, readHandler*, void*);
+void formatExifInterpretedValue (const char*, int, const char*, size_t, int, exiv2Buffer*, exiv2Error*);
+int initializeExiv2 (void);
+void lookupTag (const char*, exiv2TagInfo*, exiv2Error*);
+void onXMPLock(int);
+void readCollectionFromBytes (const unsigned char*, size_t, readOptions*, exiv2Error*, exiv2Buffer*);
+void readCollectionFromFile (const char*, readOptions*, exiv2Error*, exiv2Buffer*);
+void readCollectionFromURL (const char*, readOptions*, exiv2Error*, exiv2Buffer*);
+void registerXMPNamespace (const char*, const char*, exiv2Error*);
 
 #ifdef __cplusplus
 }
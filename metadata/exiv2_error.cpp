@@ -0,0 +1,94 @@
+#include <cstring>
+
+#include <exiv2/exiv2.hpp>
+
+#include "exiv2.h"
+
+// Exiv2 has dozens of error codes, most of which boil down to a handful of conditions callers actually care about.
+
+int getErrorKind (int code)
+{
+     switch (code)
+     {
+          case Exiv2::kerCallFailed:
+          case Exiv2::kerDataSourceOpenFailed:
+          case Exiv2::kerFailedToMapFileForReadWrite:
+          case Exiv2::kerFileOpenFailed:
+          case Exiv2::kerInputDataReadFailed:
+          case Exiv2::kerMemoryTransferFailed:
+          case Exiv2::kerTransferFailed:
+          {
+               return EXIV2_ERROR_KIND_IO;
+          }
+
+          case Exiv2::kerFileContainsUnknownImageType:
+          case Exiv2::kerFunctionNotSupported:
+          case Exiv2::kerMemoryContainsUnknownImageType:
+          case Exiv2::kerNoImageInInputData:
+          case Exiv2::kerNotACrwImage:
+          case Exiv2::kerNotAJpeg:
+          case Exiv2::kerNotAnImage:
+          case Exiv2::kerUnsupportedImageType:
+          {
+               return EXIV2_ERROR_KIND_UNSUPPORTED_FORMAT;
+          }
+
+          case Exiv2::kerArithmeticOverflow:
+          case Exiv2::kerCorruptedMetadata:
+          case Exiv2::kerDataAreaValueTooLarge:
+          case Exiv2::kerDecodeLangAltPropertyFailed:
+          case Exiv2::kerDecodeLangAltQualifierFailed:
+          case Exiv2::kerFailedToReadImageData:
+          case Exiv2::kerInvalidCharset:
+          case Exiv2::kerInvalidIccProfile:
+          case Exiv2::kerInvalidIfdId:
+          case Exiv2::kerInvalidMalloc:
+          case Exiv2::kerInvalidTypeValue:
+          case Exiv2::kerInvalidXMP:
+          case Exiv2::kerInvalidXmpText:
+          case Exiv2::kerMultipleTiffArrayElementTagsInDirectory:
+          case Exiv2::kerOffsetOutOfRange:
+          case Exiv2::kerTiffDirectoryTooLarge:
+          case Exiv2::kerTooLargeJpegSegment:
+          case Exiv2::kerTooManyTiffDirectoryEntries:
+          case Exiv2::kerUnsupportedDataAreaOffsetType:
+          case Exiv2::kerUnsupportedDateFormat:
+          case Exiv2::kerUnsupportedTimeFormat:
+          case Exiv2::kerValueTooLarge:
+          case Exiv2::kerWrongTiffArrayElementTagType:
+          case Exiv2::kerXMPToolkitError:
+          {
+               return EXIV2_ERROR_KIND_CORRUPT;
+          }
+     }
+
+     return EXIV2_ERROR_KIND_OTHER;
+}
+
+// Only the first error is reported, since anything after it is most likely a consequence of it.
+
+void setExiv2Error (exiv2Error *err, const Exiv2::AnyError &e)
+{
+     if (err->message != NULL)
+     {
+          return;
+     }
+
+     err->code = e.code();
+     err->kind = getErrorKind(e.code());
+     err->message = strdup(e.what());
+}
+
+// Anything other than an Exiv2 error (e.g., std::bad_alloc) can't be allowed to escape into Go either.
+
+void setUnknownError (exiv2Error *err, const char *message)
+{
+     if (err->message != NULL)
+     {
+          return;
+     }
+
+     err->code = Exiv2::kerGeneralError;
+     err->kind = EXIV2_ERROR_KIND_OTHER;
+     err->message = strdup(message);
+}
@@ -0,0 +1,61 @@
+#include <cstdlib>
+#include <cstring>
+#include <sstream>
+#include <string>
+
+#include <exiv2/exiv2.hpp>
+
+#include "exiv2.h"
+
+void copyToBuffer (const std::string&, exiv2Buffer*);
+void setExiv2Error (exiv2Error*, const Exiv2::AnyError&);
+void setUnknownError (exiv2Error*, const char*);
+
+void formatExifInterpretedValue (const char *key, int typeId, const char *values, size_t length, int byteOrder,
+     exiv2Buffer *buf, exiv2Error *err)
+{
+     try
+     {
+          Exiv2::ExifKey exifKey(key);
+          std::ostringstream os;
+          Exiv2::Value::AutoPtr value = Exiv2::Value::create((Exiv2::TypeId) typeId);
+
+          // Rebuild the value from its serialized form (see writeValues() in exiv2_read.cpp).  Strings are written as a
+          // length followed by the string, and everything else as it appears in the image.
+
+          switch (typeId)
+          {
+               case Exiv2::TypeId::asciiString:
+               case Exiv2::TypeId::comment:
+               {
+                    if (length >= 4)
+                    {
+                         value->read(std::string(values + 4, length - 4));
+                    }
+
+                    break;
+               }
+
+               default:
+               {
+                    value->read((const Exiv2::byte *) values, length, (Exiv2::ByteOrder) byteOrder);
+
+                    break;
+               }
+          }
+
+          os << Exiv2::Exifdatum(exifKey, value.get());
+
+          copyToBuffer(os.str(), buf);
+     }
+
+     catch (Exiv2::AnyError &e)
+     {
+          setExiv2Error(err, e);
+     }
+
+     catch (std::exception &e)
+     {
+          setUnknownError(err, e.what());
+     }
+}
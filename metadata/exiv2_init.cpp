@@ -0,0 +1,22 @@
+#include <exiv2/exiv2.hpp>
+
+#include "exiv2.h"
+
+void collectLogMessage (int, const char*);
+
+static void lockXMPToolkit (void *pLockData, bool lockUnlock)
+{
+     onXMPLock(lockUnlock ? 1 : 0);
+}
+
+int initializeExiv2 (void)
+{
+     // Exiv2 writes warnings to stderr by default, but we'd rather hand them over along with the metadata being read.
+
+     Exiv2::LogMsg::setHandler(collectLogMessage);
+
+     // The XMP toolkit must be initialized with a lock function before it can safely be used from multiple threads.
+     // Exiv2 only honors the first call, so this must happen before anything else touches XMP.
+
+     return Exiv2::XmpParser::initialize(lockXMPToolkit, NULL) ? 1 : 0;
+}
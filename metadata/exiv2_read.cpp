@@ -1,5 +1,7 @@
+#include <cstdlib>
 #include <cstring>
 #include <sstream>
+#include <string>
 #include <vector>
 
 #include <exiv2/basicio.hpp>
@@ -7,6 +9,115 @@
 
 #include "exiv2.h"
 
+void setExiv2Error (exiv2Error*, const Exiv2::AnyError&);
+void setUnknownError (exiv2Error*, const char*);
+
+// Metadata is serialized into a single buffer that is decoded on the Go side in one pass (see decode.go for a
+// description of the format).  All integers are written in little endian byte order, regardless of platform.
+
+// Exiv2 log messages are collected while reading an image so they can be returned along with its metadata.  The
+// handler is global, but reads happen on many threads at once, so each thread collects its own messages.
+
+struct LogMessage
+{
+     int level;
+     std::string message;
+};
+
+thread_local std::vector<LogMessage> *logMessages = NULL;
+
+class LogMessageCollector
+{
+public:
+     LogMessageCollector ()
+     {
+          logMessages = &messages;
+     }
+
+     ~LogMessageCollector ()
+     {
+          logMessages = NULL;
+     }
+
+     std::vector<LogMessage> messages;
+};
+
+void collectLogMessage (int level, const char *message)
+{
+     // Messages logged outside of a read (e.g., while formatting an interpreted value) are handled as usual.
+
+     if (logMessages == NULL)
+     {
+          Exiv2::LogMsg::defaultHandler(level, message);
+
+          return;
+     }
+
+     logMessages->push_back(LogMessage{level, std::string(message)});
+}
+
+// Thrown when one of the limits in readOptions is exceeded.  A limit of zero means there is no limit.
+
+struct LimitExceeded
+{
+     int limit;
+};
+
+void checkLimit (int limit, size_t maximum, size_t value)
+{
+     if (maximum > 0 && value > maximum)
+     {
+          throw LimitExceeded{limit};
+     }
+}
+
+void copyToBuffer (const std::string &buffer, exiv2Buffer *buf)
+{
+     buf->data = (char *) malloc(buffer.size());
+     buf->length = buffer.size();
+
+     memcpy(buf->data, buffer.data(), buffer.size());
+}
+
+void writeUInt8 (std::string &buffer, uint8_t value)
+{
+     buffer.push_back((char) value);
+}
+
+void writeUInt16 (std::string &buffer, uint16_t value)
+{
+     buffer.push_back((char) (value & 0xFF));
+     buffer.push_back((char) ((value >> 8) & 0xFF));
+}
+
+void writeUInt32 (std::string &buffer, uint32_t value)
+{
+     for (int i = 0; i < 4; ++i)
+     {
+          buffer.push_back((char) ((value >> (i * 8)) & 0xFF));
+     }
+}
+
+void writeInt32 (std::string &buffer, int32_t value)
+{
+     writeUInt32(buffer, (uint32_t) value);
+}
+
+void writeString (std::string &buffer, const std::string &value)
+{
+     writeUInt32(buffer, value.size());
+
+     buffer.append(value);
+}
+
+void patchUInt32 (std::string &buffer, size_t offset, uint32_t value)
+{
+     for (int i = 0; i < 4; ++i)
+     {
+          buffer[offset + i] = (char) ((value >> (i * 8)) & 0xFF);
+     }
+}
+
 long getAdjustedCount (Exiv2::TypeId typeId, long count)
 {
      switch (typeId)
@@ -34,21 +145,24 @@ long getAdjustedCount (Exiv2::TypeId typeId, long count)
      return count;
 }
 
-void notifyValueCreated (valueHolder *vh, const Exiv2::Value &value, int index, readHandler *handler, void *rhPointer)
+void writeValues (std::string &buffer, const Exiv2::Value &value, long count, Exiv2::ByteOrder byteOrder)
 {
-     vh->strValue = NULL;
-
      switch (value.typeId())
      {
           case Exiv2::TypeId::asciiString:
-          case Exiv2::TypeId::comment:
-          case Exiv2::TypeId::string:
-          case Exiv2::TypeId::xmpAlt:
-          case Exiv2::TypeId::xmpBag:
-          case Exiv2::TypeId::xmpSeq:
           case Exiv2::TypeId::xmpText:
-         {
-               vh->strValue = strdup(value.toString(index).c_str());
+          {
+               writeString(buffer, value.toString());
+
+               break;
+          }
+
+          case Exiv2::TypeId::comment:
+          {
+               // The string value of a comment is prefixed with its character set (e.g., "charset=Ascii"), but we're
+               // only interested in the comment itself.  Exiv2 takes care of converting Unicode comments to UTF-8.
+
+               writeString(buffer, static_cast<const Exiv2::CommentValue&>(value).comment());
 
                break;
           }
@@ -57,172 +171,407 @@ void notifyValueCreated (valueHolder *vh, const Exiv2::Value &value, int index,
           {
                auto date = static_cast<const Exiv2::DateValue&>(value).getDate();
 
-               vh->dayValue = date.day;
-               vh->monthValue = date.month;
-               vh->yearValue = date.year;
+               writeInt32(buffer, date.year);
+               writeInt32(buffer, date.month);
+               writeInt32(buffer, date.day);
 
                break;
           }
 
           case Exiv2::TypeId::langAlt:
           {
-               auto langAltMap = static_cast<const Exiv2::LangAltValue&>(value).value_;
-
-               // XMPLangAlt is a little different from normal values since it's a map.  We have to iterate over it and
-               // notify that a new value has been created for each key/value pair.
+               // XMPLangAlt is a little different from normal values since it's a map, so we write out each key/value
+               // pair.
 
-               for (auto langAlt : langAltMap)
+               for (auto langAlt : static_cast<const Exiv2::LangAltValue&>(value).value_)
                {
-                    vh->langValue = langAlt.first.c_str();
-                    vh->strValue = strdup(langAlt.second.c_str());
-
-                    handler->vc(rhPointer, vh);
-
-                    free(vh->strValue);
+                    writeString(buffer, langAlt.first);
+                    writeString(buffer, langAlt.second);
                }
 
-               return;
+               break;
           }
 
-          case Exiv2::TypeId::signedByte:
-          case Exiv2::TypeId::signedLong:
-          case Exiv2::TypeId::signedShort:
-          case Exiv2::TypeId::undefined:
-          case Exiv2::TypeId::unsignedByte:
-          case Exiv2::TypeId::unsignedLong:
-          case Exiv2::TypeId::unsignedShort:
+          case Exiv2::TypeId::string:
           {
-               vh->longValue = value.toLong(index);
+               // IPTC strings aren't necessarily UTF-8 (or even NUL-free), so we write the raw bytes and let the Go
+               // side deal with character set conversion.
+
+               writeString(buffer, value.toString());
 
                break;
           }
 
-          case Exiv2::TypeId::signedRational:
-          case Exiv2::TypeId::unsignedRational:
+          case Exiv2::TypeId::time:
           {
-               auto rationalValue = value.toRational(index);
+               auto time = static_cast<const Exiv2::TimeValue&>(value).getTime();
 
-               vh->rationalValueN = rationalValue.first;
-               vh->rationalValueD = rationalValue.second;
+               writeInt32(buffer, time.hour);
+               writeInt32(buffer, time.minute);
+               writeInt32(buffer, time.second);
+               writeInt32(buffer, time.tzHour);
+               writeInt32(buffer, time.tzMinute);
 
                break;
           }
 
-          case Exiv2::TypeId::tiffDouble:
+          case Exiv2::TypeId::xmpAlt:
+          case Exiv2::TypeId::xmpBag:
+          case Exiv2::TypeId::xmpSeq:
           {
-               // The Exiv2::Value class doesn't seem to have a function for getting a double value, just a float, so we
-               // have to read it in the hard way.
-
-               auto doubleValue = static_cast<const Exiv2::ValueType<double>&>(value);
-               auto doubleValues = static_cast<std::vector<double>>(doubleValue.value_);
-
-               vh->doubleValue = doubleValues[index];
+               for (long i = 0; i < count; ++i)
+               {
+                    writeString(buffer, value.toString(i));
+               }
 
                break;
           }
 
-          case Exiv2::TypeId::tiffFloat:
+          default:
           {
-               vh->doubleValue = value.toFloat(index);
+               // Everything else is numeric (or raw bytes), so we can just copy the values as they appear in the image,
+               // which is far cheaper than converting them one by one.
+
+               long size = value.size();
+
+               if (size > 0)
+               {
+                    std::vector<Exiv2::byte> data(size);
+
+                    value.copy(data.data(), byteOrder);
+
+                    buffer.append((const char *) data.data(), size);
+               }
 
                break;
           }
+     }
+}
 
-          case Exiv2::TypeId::time:
-          {
-               auto time = static_cast<const Exiv2::TimeValue&>(value).getTime();
+void writeMetadatum (std::string &buffer, const Exiv2::Metadatum &metadatum, int family, std::ostringstream &os,
+     int flags, Exiv2::ByteOrder byteOrder, readOptions *options, size_t &numProperties)
+{
+     long count = getAdjustedCount(metadatum.typeId(), metadatum.count());
+     size_t start = buffer.size();
+     size_t valuesLengthOffset;
 
-               vh->hourValue = time.hour;
-               vh->minuteValue = time.minute;
-               vh->secondValue = time.second;
-               vh->timezoneHourOffset = time.tzHour;
-               vh->timezoneMinuteOffset = time.tzMinute;
+     // Check the limits we can before doing any work, so that a crafted image can't make us allocate huge amounts of
+     // memory converting values.
 
-               break;
+     checkLimit(EXIV2_LIMIT_PROPERTIES, options->maxProperties, ++numProperties);
+     checkLimit(EXIV2_LIMIT_VALUES_PER_PROPERTY, options->maxValuesPerProperty, count);
+
+     // A partially written property would make the buffer impossible to decode, which matters when reading leniently
+     // (see readMetadata()), so we'll roll back whatever was written if anything goes wrong.
+
+     try
+     {
+          os.clear();
+          os.str("");
+
+          // The interpreted value can only(?) be obtained via operator<<.  That's relatively expensive for Exif
+          // properties, so when reading lazily we'll let the Go side ask for it later (see
+          // formatExifInterpretedValue()).
+
+          if (options->lazy && family == EXIV2_FAMILY_EXIF)
+          {
+               flags |= EXIV2_PROPERTY_DEFERRED_INTERPRETED_VALUE;
+          }
+
+          else
+          {
+               os << metadatum;
           }
+
+          writeUInt8(buffer, family);
+          writeString(buffer, metadatum.groupName());
+          writeString(buffer, metadatum.tagName());
+          writeUInt16(buffer, metadatum.tag());
+          writeString(buffer, metadatum.tagLabel());
+          writeString(buffer, os.str());
+          writeUInt32(buffer, metadatum.typeId());
+          writeUInt8(buffer, flags);
+          writeUInt32(buffer, count);
+
+          // The length of the values isn't known until they've been written, so we'll fill it in afterwards.
+
+          valuesLengthOffset = buffer.size();
+
+          writeUInt32(buffer, 0);
+          writeValues(buffer, metadatum.value(), count, byteOrder);
+          patchUInt32(buffer, valuesLengthOffset, buffer.size() - valuesLengthOffset - 4);
+
+          checkLimit(EXIV2_LIMIT_TOTAL_BYTES, options->maxTotalBytes, buffer.size());
      }
 
-     handler->vc(rhPointer, vh);
+     catch (...)
+     {
+          buffer.resize(start);
+
+          throw;
+     }
+}
 
-     if (vh->strValue)
+void writeLogMessages (std::string &buffer, const std::vector<LogMessage> &messages)
+{
+     for (auto &logMessage : messages)
      {
-          free(vh->strValue);
+          writeUInt8(buffer, EXIV2_RECORD_LOG_MESSAGE);
+          writeUInt8(buffer, logMessage.level);
+          writeString(buffer, logMessage.message);
      }
 }
 
-void handleMetadatum (const Exiv2::Metadatum& metadatum, std::ostringstream& buffer, int repeatable, valueHolder *vh,
-     readHandler *handler, void *rhPointer)
+// Matches a key against a pattern, where * matches any sequence of characters and ? matches any single character.
+
+bool matchPattern (const char *pattern, const char *key)
 {
-     long count = getAdjustedCount(metadatum.typeId(), metadatum.count());
-     std::string interpretedValue;
+     const char *starPattern = NULL;
+     const char *starKey = NULL;
+
+     while (*key != '\0')
+     {
+          if (*pattern == '*')
+          {
+               starPattern = pattern++;
+               starKey = key;
+          }
+
+          else if (*pattern == '?' || *pattern == *key)
+          {
+               ++pattern;
+               ++key;
+          }
 
-     buffer.clear();
-     buffer.str("");
+          // On a mismatch, let the most recent * consume one more character and try again.
 
-     // The interpreted value can only(?) be obtained via operator<<.
+          else if (starPattern != NULL)
+          {
+               pattern = starPattern + 1;
+               key = ++starKey;
+          }
+
+          else
+          {
+               return false;
+          }
+     }
+
+     while (*pattern == '*')
+     {
+          ++pattern;
+     }
+
+     return *pattern == '\0';
+}
+
+bool isKeySelected (readOptions *options, const std::string &key)
+{
+     if (options->numPatterns == 0)
+     {
+          return true;
+     }
 
-     buffer << metadatum;
+     for (size_t i = 0; i < options->numPatterns; ++i)
+     {
+          if (matchPattern(options->patterns[i], key.c_str()))
+          {
+               return true;
+          }
+     }
 
-     interpretedValue = buffer.str();
+     return false;
+}
 
-     // Notify that new metadata has been encountered.
+// Determines whether any pattern could possibly select a key in the given family, so that we can skip entire families
+// without looking at their keys.
 
-     handler->posc(rhPointer, metadatum.familyName(), metadatum.groupName().c_str(), metadatum.tagName().c_str(),
-          (int) metadatum.typeId(), metadatum.tagLabel().c_str(), interpretedValue.c_str(), count, repeatable);
+bool isFamilySelected (readOptions *options, const std::string &family)
+{
+     std::string prefix = family + ".";
 
-     for (int i = 0; i < count; ++i)
+     if (options->numPatterns == 0)
      {
-          notifyValueCreated(vh, metadatum.value(), i, handler, rhPointer);
+          return true;
      }
 
-     // Notify that we've finished processing the metadata.
+     for (size_t i = 0; i < options->numPatterns; ++i)
+     {
+          std::string pattern(options->patterns[i]);
+          std::string literal = pattern.substr(0, pattern.find_first_of("*?"));
+
+          if (literal.compare(0, prefix.size(), prefix) == 0 || prefix.compare(0, literal.size(), literal) == 0)
+          {
+               return true;
+          }
+     }
 
-     handler->poec(rhPointer, metadatum.familyName());
+     return false;
 }
 
-void readMetadata (Exiv2::Image::AutoPtr image, exiv2Error *err, valueHolder *vh, readHandler *handler, void *rhPointer)
+void readMetadata (Exiv2::BasicIo::AutoPtr io, readOptions *options, exiv2Error *err, exiv2Buffer *buf)
 {
+     std::string buffer;
+     LogMessageCollector collector;
+
      try
      {
-          std::ostringstream buffer;
+          Exiv2::ByteOrder byteOrder;
+          Exiv2::Image::AutoPtr image;
+          size_t numProperties = 0;
+          std::ostringstream os;
+          std::string path = io->path();
+
+          // Unlike the other variants, opening a BasicIo returns a null image rather than throwing when the image type
+          // isn't recognized.
 
-          image->readMetadata();
+          image = Exiv2::ImageFactory::open(io);
 
-          for (auto &exifDatum : image->exifData())
+          if (image.get() == NULL)
           {
-               handleMetadatum(exifDatum, buffer, 0, vh, handler, rhPointer);
+               throw Exiv2::Error(Exiv2::kerFileContainsUnknownImageType, path);
           }
 
-          for (auto &iptcDatum : image->iptcData())
+          try
+          {
+               image->readMetadata();
+          }
+
+          catch (Exiv2::AnyError &e)
+          {
+               // Exiv2 keeps whatever it managed to read before giving up, which is worth having when reading
+               // leniently.
+
+               if (!options->lenient)
+               {
+                    throw;
+               }
+
+               setExiv2Error(err, e);
+          }
+
+          // Exiv2 doesn't give us a chance to look at the XMP packet before it's parsed, so this isn't a safety limit,
+          // but we can at least avoid handing over the properties of an oversized packet.
+
+          checkLimit(EXIV2_LIMIT_XMP_PACKET_SIZE, options->maxXMPPacketSize, image->xmpPacket().size());
+
+          // Binary Exif values (e.g., Exif.Photo.OECF) are encoded using the byte order of the image.  An image without
+          // Exif metadata has an invalid byte order, but we need something to encode the values that do exist with.
+
+          byteOrder = image->byteOrder();
+
+          writeUInt8(buffer, EXIV2_BUFFER_VERSION);
+          writeUInt8(buffer, byteOrder);
+
+          if (byteOrder == Exiv2::invalidByteOrder)
           {
-               handleMetadatum(iptcDatum, buffer, Exiv2::IptcDataSets::dataSetRepeatable(iptcDatum.tag(),
-                    iptcDatum.record()) ? 1 : 0, vh, handler, rhPointer);
+               byteOrder = Exiv2::littleEndian;
           }
 
-          for (auto &xmpDatum : image->xmpData())
+          if (isFamilySelected(options, "Exif"))
           {
-               handleMetadatum(xmpDatum, buffer, 0, vh, handler, rhPointer);
+               for (auto &exifDatum : image->exifData())
+               {
+                    if (isKeySelected(options, exifDatum.key()))
+                    {
+                         writeMetadatum(buffer, exifDatum, EXIV2_FAMILY_EXIF, os, 0, byteOrder, options, numProperties);
+                    }
+               }
           }
+
+          if (isFamilySelected(options, "Iptc"))
+          {
+               for (auto &iptcDatum : image->iptcData())
+               {
+                    int flags = Exiv2::IptcDataSets::dataSetRepeatable(iptcDatum.tag(), iptcDatum.record()) ?
+                         EXIV2_PROPERTY_REPEATABLE : 0;
+
+                    if (!isKeySelected(options, iptcDatum.key()))
+                    {
+                         // The character set dataset is always needed in order to decode IPTC strings, so we'll send
+                         // it along and let the Go side discard it afterwards.
+
+                         if (iptcDatum.record() != Exiv2::IptcDataSets::envelope ||
+                              iptcDatum.tag() != Exiv2::IptcDataSets::CharacterSet)
+                         {
+                              continue;
+                         }
+
+                         flags |= EXIV2_PROPERTY_UNSELECTED;
+                    }
+
+                    writeMetadatum(buffer, iptcDatum, EXIV2_FAMILY_IPTC, os, flags, byteOrder, options, numProperties);
+               }
+          }
+
+          if (isFamilySelected(options, "Xmp"))
+          {
+               for (auto &xmpDatum : image->xmpData())
+               {
+                    if (isKeySelected(options, xmpDatum.key()))
+                    {
+                         writeMetadatum(buffer, xmpDatum, EXIV2_FAMILY_XMP, os, 0, byteOrder, options, numProperties);
+                    }
+               }
+          }
+
+          writeLogMessages(buffer, collector.messages);
+          copyToBuffer(buffer, buf);
+
+          return;
      }
 
-     catch (Exiv2::Error &e)
+     catch (Exiv2::AnyError &e)
      {
-          err->code = e.code();
-          err->message = strdup(e.what());
+          setExiv2Error(err, e);
      }
+
+     catch (LimitExceeded &e)
+     {
+          // When reading leniently, an Exiv2 error may already have been recorded, but exceeding a limit means the
+          // metadata is incomplete for a reason the caller asked for, so it takes precedence.
+
+          free((void *) err->message);
+
+          err->code = 0;
+          err->kind = EXIV2_ERROR_KIND_OTHER;
+          err->limit = e.limit;
+          err->message = strdup("read limit exceeded");
+     }
+
+     catch (std::exception &e)
+     {
+          setUnknownError(err, e.what());
+     }
+
+     // When reading leniently, everything serialized up to the point of failure is handed over along with the error
+     // (as long as we got as far as writing the header).
+
+     if (options->lenient && !buffer.empty())
+     {
+          writeLogMessages(buffer, collector.messages);
+          copyToBuffer(buffer, buf);
+     }
+}
+
+void readCollectionFromBytes (const unsigned char *data, size_t length, readOptions *options, exiv2Error *err,
+     exiv2Buffer *buf)
+{
+     Exiv2::BasicIo::AutoPtr ptr(new Exiv2::MemIo((const Exiv2::byte *) data, (long) length));
+
+     readMetadata(ptr, options, err, buf);
 }
 
-void readCollectionFromFile (const char *filename, exiv2Error *err, valueHolder *vh, readHandler *handler,
-     void *rhPointer)
+void readCollectionFromFile (const char *filename, readOptions *options, exiv2Error *err, exiv2Buffer *buf)
 {
      Exiv2::BasicIo::AutoPtr ptr(new Exiv2::FileIo(std::string(filename)));
 
-     readMetadata(Exiv2::ImageFactory::open(ptr), err, vh, handler, rhPointer);
+     readMetadata(ptr, options, err, buf);
 }
 
-void readCollectionFromURL (const char *url, exiv2Error *err, valueHolder *vh, readHandler *handler, void *rhPointer)
+void readCollectionFromURL (const char *url, readOptions *options, exiv2Error *err, exiv2Buffer *buf)
 {
      Exiv2::BasicIo::AutoPtr ptr(new Exiv2::HttpIo(std::string(url)));
 
-     readMetadata(Exiv2::ImageFactory::open(ptr), err, vh, handler, rhPointer);
+     readMetadata(ptr, options, err, buf);
 }
@@ -0,0 +1,67 @@
+#include <cstdlib>
+#include <cstring>
+#include <string>
+
+#include <exiv2/exiv2.hpp>
+
+#include "exiv2.h"
+
+void setExiv2Error (exiv2Error*, const Exiv2::AnyError&);
+void setUnknownError (exiv2Error*, const char*);
+
+void lookupTag (const char *key, exiv2TagInfo *info, exiv2Error *err)
+{
+     try
+     {
+          std::string keyString(key);
+
+          // The key constructors throw if Exiv2 doesn't know about the property (or, for XMP properties, about the
+          // namespace prefix).
+
+          if (keyString.compare(0, 5, "Exif.") == 0)
+          {
+               Exiv2::ExifKey exifKey(keyString);
+
+               info->label = strdup(exifKey.tagLabel().c_str());
+               info->tag = exifKey.tag();
+               info->typeId = exifKey.defaultTypeId();
+          }
+
+          else if (keyString.compare(0, 5, "Iptc.") == 0)
+          {
+               Exiv2::IptcKey iptcKey(keyString);
+
+               info->label = strdup(iptcKey.tagLabel().c_str());
+               info->repeatable = Exiv2::IptcDataSets::dataSetRepeatable(iptcKey.tag(), iptcKey.record());
+               info->tag = iptcKey.tag();
+               info->typeId = Exiv2::IptcDataSets::dataSetType(iptcKey.tag(), iptcKey.record());
+          }
+
+          else
+          {
+               Exiv2::XmpKey xmpKey(keyString);
+
+               // Unlike the other key constructors, XmpKey accepts any property of a known namespace.  Namespaces
+               // registered at runtime have no property list, so any of their properties is fine.
+
+               if (Exiv2::XmpProperties::propertyList(xmpKey.groupName()) != 0 &&
+                    Exiv2::XmpProperties::propertyInfo(xmpKey) == 0)
+               {
+                    throw Exiv2::Error(Exiv2::kerInvalidKey, keyString);
+               }
+
+               info->label = strdup(xmpKey.tagLabel().c_str());
+               info->typeId = Exiv2::XmpProperties::propertyType(xmpKey);
+          }
+     }
+
+     catch (Exiv2::AnyError &e)
+     {
+          setExiv2Error(err, e);
+     }
+
+     catch (std::exception &e)
+     {
+          setUnknownError(err, e.what());
+     }
+}
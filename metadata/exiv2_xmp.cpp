@@ -0,0 +1,28 @@
+#include <cstring>
+
+#include <exiv2/exiv2.hpp>
+
+#include "exiv2.h"
+
+void setExiv2Error (exiv2Error*, const Exiv2::AnyError&);
+void setUnknownError (exiv2Error*, const char*);
+
+void registerXMPNamespace (const char *uri, const char *prefix, exiv2Error *err)
+{
+     try
+     {
+          // Exiv2 takes care of replacing any existing registration for the prefix.
+
+          Exiv2::XmpProperties::registerNs(std::string(uri), std::string(prefix));
+     }
+
+     catch (Exiv2::AnyError &e)
+     {
+          setExiv2Error(err, e);
+     }
+
+     catch (std::exception &e)
+     {
+          setUnknownError(err, e.what());
+     }
+}
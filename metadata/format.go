@@ -0,0 +1,56 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+/*
+#include <stdlib.h>
+
+#include "exiv2.h"
+*/
+import "C"
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+//
+// Private functions
+//
+
+// formatExifInterpretedValue asks Exiv2 to format the interpreted value of an Exif property whose interpreted value was
+// deferred when it was read (see Lazy()).
+func formatExifInterpretedValue(property *propertyImpl) (string, error) {
+	var cBuffer = C.struct_exiv2Buffer{}
+	var cByteOrder = C.int(byteOrderLittleEndian)
+	var cExiv2Error = C.struct_exiv2Error{
+		code: C.int(-999),
+	}
+	var cKey = C.CString(property.key())
+	var cValues *C.char
+
+	defer C.free(unsafe.Pointer(cKey))
+
+	if property.byteOrder == binary.BigEndian {
+		cByteOrder = C.int(byteOrderBigEndian)
+	}
+
+	if len(property.encodedValues) > 0 {
+		cValues = (*C.char)(C.CBytes(property.encodedValues))
+
+		defer C.free(unsafe.Pointer(cValues))
+	}
+
+	C.formatExifInterpretedValue(cKey, C.int(property.typeId), cValues, C.size_t(len(property.encodedValues)),
+		cByteOrder, &cBuffer, &cExiv2Error)
+
+	if cBuffer.data != nil {
+		defer C.free(unsafe.Pointer(cBuffer.data))
+	}
+
+	if cExiv2Error.code != C.int(-999) {
+		defer C.free(unsafe.Pointer(cExiv2Error.message))
+
+		return "", newError(int(cExiv2Error.code), int(cExiv2Error.kind), C.GoString(cExiv2Error.message))
+	}
+
+	return C.GoStringN(cBuffer.data, C.int(cBuffer.length)), nil
+}
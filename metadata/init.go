@@ -0,0 +1,69 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+/*
+#include "exiv2.h"
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"golang.handcraftedbits.com/ezif/internal"
+)
+
+//
+// Public functions
+//
+
+// Initialize prepares Exiv2 for concurrent use, most importantly by initializing the XMP toolkit with a lock function.
+// It is safe to call Initialize multiple times from multiple goroutines; only the first call has any effect.  Since
+// Initialize is called automatically before any metadata is read, calling it explicitly is only necessary in order to
+// detect initialization errors early.  Initialize logs using the default logger, which is configured using the
+// EZIF_LOG_FORMAT and EZIF_LOG_LEVEL environment variables; when Exiv2 is initialized automatically by a read, the
+// logger of that read (see Logger()) is used instead.
+func Initialize() error {
+	return initialize(context.Background(), internal.Log)
+}
+
+//
+// Private variables
+//
+
+var (
+	initializeErr  error
+	initializeOnce sync.Once
+
+	// Exiv2 only acquires the XMP toolkit lock around (un)registering namespaces and never does so recursively, so a
+	// plain mutex is sufficient.
+	xmpToolkitMutex sync.Mutex
+)
+
+//
+// Private functions
+//
+
+//export onXMPLockGo
+func onXMPLockGo(lock C.int) {
+	if int(lock) == 1 {
+		xmpToolkitMutex.Lock()
+	} else {
+		xmpToolkitMutex.Unlock()
+	}
+}
+
+func initialize(ctx context.Context, logger *slog.Logger) error {
+	initializeOnce.Do(func() {
+		if logger.Enabled(ctx, slog.LevelInfo) {
+			logger.InfoContext(ctx, "initializing Exiv2")
+		}
+
+		if C.initializeExiv2() == 0 {
+			initializeErr = fmt.Errorf("could not initialize the Exiv2 XMP toolkit")
+		}
+	})
+
+	return initializeErr
+}
@@ -0,0 +1,489 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.handcraftedbits.com/ezif/internal"
+)
+
+// Isolated reads are performed by a pool of ezifworker processes (see cmd/ezifworker), which speak a simple framed
+// protocol over their standard input and output.  Each frame is a u32 length followed by that many bytes of payload,
+// and all integers are little endian.  Requests and responses have the following format:
+//
+//   request:  source kind (u8), flags (u8), read limits (four u64 values, in the order of the ReadLimits fields),
+//             number of key patterns (u32), key patterns (str), source (str)
+//   response: status (u8), followed by an Exiv2 error code (i32), error kind (u8) and message (str) if the status is
+//             1, or a read limit code (u32) if the status is 2, followed by the serialized metadata buffer (see
+//             decode.go), if any
+//
+// where str is a u32 length followed by that many bytes, and the source is either a filename, a URL or the contents of
+// an image, depending on the source kind.  A worker exits once its standard input is closed.
+//
+// The only request flag is 0x01, which indicates that the image should be read leniently (see Lenient()).  A response
+// only contains both an error and a metadata buffer when reading leniently.
+
+//
+// Public types
+//
+
+// IsolatedReader reads image metadata in a pool of worker processes, protecting the calling process from crashes in
+// Exiv2 caused by malformed images.  Workers that crash, time out or exceed their memory limit are restarted
+// automatically the next time they're needed.  An IsolatedReader is used by passing it to the Isolated() read option,
+// and is safe for concurrent use.
+type IsolatedReader struct {
+	closeOnce sync.Once
+	config    IsolatedReaderConfig
+	done      chan struct{}
+	workers   chan *isolatedWorker
+}
+
+// Close stops all worker processes, waiting for any reads in progress to finish.  Reads attempted after Close has been
+// called return an error.
+func (reader *IsolatedReader) Close() error {
+	reader.closeOnce.Do(func() {
+		close(reader.done)
+
+		for i := 0; i < reader.config.Workers; i++ {
+			_ = (<-reader.workers).stop(context.Background())
+		}
+	})
+
+	return nil
+}
+
+func (reader *IsolatedReader) readBuffer(source *imageSource, options *readOptions) ([]byte, error) {
+	var response []byte
+	var err error
+	var worker *isolatedWorker
+
+	select {
+	case <-reader.done:
+		return nil, fmt.Errorf("isolated reader is closed")
+
+	case worker = <-reader.workers:
+	}
+
+	defer func() {
+		reader.workers <- worker
+	}()
+
+	if response, err = worker.roundTrip(options.ctx, &reader.config, encodeWorkerRequest(source, options)); err != nil {
+		return nil, err
+	}
+
+	return decodeWorkerResponse(response, options)
+}
+
+// IsolatedReaderConfig is used to configure an IsolatedReader.
+type IsolatedReaderConfig struct {
+	// Logger is used to log the starting and stopping of worker processes.  If nil, the default ezif logger is used.
+	// Note that it doesn't affect reads, which use the logger given by the Logger() read option.
+	Logger *slog.Logger
+
+	// MemoryLimit is the maximum amount of memory, in bytes, a worker process may use.  A value of zero means there is
+	// no limit.
+	MemoryLimit uint64
+
+	// Timeout is the maximum amount of time a worker process may spend reading a single image before it is killed.  A
+	// value of zero means there is no timeout.
+	Timeout time.Duration
+
+	// WorkerPath is the path of the ezifworker binary.  If empty, ezifworker is searched for in the directories named by
+	// the PATH environment variable.
+	WorkerPath string
+
+	// Workers is the number of worker processes, which defaults to the number of CPUs.
+	Workers int
+}
+
+//
+// Public functions
+//
+
+// ServeIsolatedReads reads requests from reader and writes the corresponding responses to writer until reader reaches
+// EOF.  It is used to implement the ezifworker binary, and is of little use otherwise.
+func ServeIsolatedReads(reader io.Reader, writer io.Writer) error {
+	for {
+		var buffer []byte
+		var err error
+		var options *readOptions
+		var request []byte
+		var source *imageSource
+
+		if request, err = readFrame(reader); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+
+		if source, options, err = decodeWorkerRequest(request); err != nil {
+			return fmt.Errorf("invalid isolated read request: %v", err)
+		}
+
+		buffer, err = readBuffer(source, options)
+
+		if err = writeFrame(writer, encodeWorkerResponse(buffer, err)); err != nil {
+			return err
+		}
+	}
+}
+
+// NewIsolatedReader creates a new IsolatedReader.  Worker processes are started as they're needed.
+func NewIsolatedReader(config IsolatedReaderConfig) (*IsolatedReader, error) {
+	var err error
+	var reader *IsolatedReader
+
+	if config.WorkerPath == "" {
+		config.WorkerPath = isolatedWorkerName
+	}
+
+	if config.WorkerPath, err = exec.LookPath(config.WorkerPath); err != nil {
+		return nil, fmt.Errorf("could not find isolated worker binary: %v", err)
+	}
+
+	if config.Logger == nil {
+		config.Logger = internal.Log
+	}
+
+	if config.Workers <= 0 {
+		config.Workers = runtime.NumCPU()
+	}
+
+	reader = &IsolatedReader{
+		config:  config,
+		done:    make(chan struct{}),
+		workers: make(chan *isolatedWorker, config.Workers),
+	}
+
+	for i := 0; i < config.Workers; i++ {
+		reader.workers <- &isolatedWorker{
+			logger: config.Logger,
+		}
+	}
+
+	return reader, nil
+}
+
+//
+// Private types
+//
+
+type isolatedWorker struct {
+	command *exec.Cmd
+	logger  *slog.Logger
+	stdin   io.WriteCloser
+	stdout  io.ReadCloser
+}
+
+func (worker *isolatedWorker) roundTrip(ctx context.Context, config *IsolatedReaderConfig,
+	request []byte) ([]byte, error) {
+	type roundTripResult struct {
+		err      error
+		response []byte
+	}
+
+	var results = make(chan roundTripResult, 1)
+	var timeout <-chan time.Time
+
+	if worker.command == nil {
+		if err := worker.start(ctx, config); err != nil {
+			return nil, err
+		}
+	}
+
+	go func() {
+		var result roundTripResult
+
+		if result.err = writeFrame(worker.stdin, request); result.err == nil {
+			result.response, result.err = readFrame(worker.stdout)
+		}
+
+		results <- result
+	}()
+
+	if config.Timeout > 0 {
+		var timer = time.NewTimer(config.Timeout)
+
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	select {
+	case result := <-results:
+		if result.err != nil {
+			// The exit status (e.g., "signal: segmentation fault") is far more useful than the I/O error.
+
+			if exitErr := worker.stop(ctx); exitErr != nil {
+				return nil, fmt.Errorf("isolated worker failed: %v", exitErr)
+			}
+
+			return nil, fmt.Errorf("isolated worker failed: %v", result.err)
+		}
+
+		return result.response, nil
+
+	case <-timeout:
+		_ = worker.stop(ctx)
+
+		// Killing the worker closes its pipes, so the round trip will finish promptly.
+
+		<-results
+
+		return nil, fmt.Errorf("isolated worker timed out after %s", config.Timeout)
+	}
+}
+
+func (worker *isolatedWorker) start(ctx context.Context, config *IsolatedReaderConfig) error {
+	var args []string
+	var err error
+
+	if config.MemoryLimit > 0 {
+		args = append(args, "--memory-limit", strconv.FormatUint(config.MemoryLimit, 10))
+	}
+
+	worker.command = exec.Command(config.WorkerPath, args...)
+
+	if worker.stdin, err = worker.command.StdinPipe(); err == nil {
+		if worker.stdout, err = worker.command.StdoutPipe(); err == nil {
+			err = worker.command.Start()
+		}
+	}
+
+	if err != nil {
+		worker.command = nil
+
+		return fmt.Errorf("could not start isolated worker: %v", err)
+	}
+
+	if worker.logger.Enabled(ctx, slog.LevelInfo) {
+		worker.logger.InfoContext(ctx, "started isolated worker",
+			"pid", worker.command.Process.Pid,
+		)
+	}
+
+	return nil
+}
+
+// stop kills the worker process and returns its exit status.
+func (worker *isolatedWorker) stop(ctx context.Context) error {
+	var err error
+
+	if worker.command == nil {
+		return nil
+	}
+
+	// Killing a process that has already exited has no effect, so its actual exit status is preserved.
+
+	_ = worker.command.Process.Kill()
+
+	err = worker.command.Wait()
+
+	if worker.logger.Enabled(ctx, slog.LevelInfo) {
+		worker.logger.InfoContext(ctx, "stopped isolated worker",
+			"pid", worker.command.Process.Pid,
+			"status", err,
+		)
+	}
+
+	worker.command = nil
+	worker.stdin = nil
+	worker.stdout = nil
+
+	return err
+}
+
+//
+// Private constants
+//
+
+const (
+	isolatedWorkerName = "ezifworker"
+
+	// A sanity check on frame lengths, so that a misbehaving worker can't make us allocate arbitrary amounts of memory.
+	maxFrameLength = 1 << 30
+
+	workerRequestFlagLenient = 0x01
+
+	workerStatusError      = 1
+	workerStatusLimitError = 2
+	workerStatusOK         = 0
+)
+
+//
+// Private functions
+//
+
+func appendWorkerBytes(buffer []byte, value []byte) []byte {
+	buffer = appendWorkerUInt32(buffer, uint32(len(value)))
+
+	return append(buffer, value...)
+}
+
+func appendWorkerUInt32(buffer []byte, value uint32) []byte {
+	var data [4]byte
+
+	binary.LittleEndian.PutUint32(data[:], value)
+
+	return append(buffer, data[:]...)
+}
+
+func appendWorkerUInt64(buffer []byte, value uint64) []byte {
+	var data [8]byte
+
+	binary.LittleEndian.PutUint64(data[:], value)
+
+	return append(buffer, data[:]...)
+}
+
+func decodeWorkerRequest(request []byte) (*imageSource, *readOptions, error) {
+	var options = newReadOptions(nil)
+	var reader = newBufferReader(request)
+	var source = &imageSource{}
+
+	source.kind = reader.readUInt8()
+	options.lenient = reader.readUInt8()&workerRequestFlagLenient != 0
+
+	options.limits.MaxProperties = int(reader.readUInt64())
+	options.limits.MaxTotalBytes = int(reader.readUInt64())
+	options.limits.MaxValuesPerProperty = int(reader.readUInt64())
+	options.limits.MaxXMPPacketSize = int(reader.readUInt64())
+
+	options.patterns = make([]string, reader.readUInt32())
+
+	for i := range options.patterns {
+		options.patterns[i] = reader.readString()
+	}
+
+	if source.kind == imageSourceBytes {
+		source.data = reader.readBytes(int(reader.readUInt32()))
+	} else {
+		source.location = reader.readString()
+	}
+
+	if reader.err != nil {
+		return nil, nil, reader.err
+	}
+
+	return source, options, nil
+}
+
+func decodeWorkerResponse(response []byte, options *readOptions) ([]byte, error) {
+	var buffer []byte
+	var err error
+	var reader = newBufferReader(response)
+
+	switch reader.readUInt8() {
+	case workerStatusOK:
+		break
+
+	case workerStatusLimitError:
+		err = newLimitError(int(reader.readUInt32()), &options.limits)
+
+	default:
+		var code = int(reader.readInt32())
+		var kindCode = int(reader.readUInt8())
+
+		err = newError(code, kindCode, reader.readString())
+	}
+
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	if reader.remaining() > 0 {
+		buffer = reader.readBytes(reader.remaining())
+	}
+
+	return buffer, err
+}
+
+func encodeWorkerRequest(source *imageSource, options *readOptions) []byte {
+	var request = []byte{source.kind, 0}
+
+	if options.lenient {
+		request[1] |= workerRequestFlagLenient
+	}
+
+	request = appendWorkerUInt64(request, uint64(nonNegative(options.limits.MaxProperties)))
+	request = appendWorkerUInt64(request, uint64(nonNegative(options.limits.MaxTotalBytes)))
+	request = appendWorkerUInt64(request, uint64(nonNegative(options.limits.MaxValuesPerProperty)))
+	request = appendWorkerUInt64(request, uint64(nonNegative(options.limits.MaxXMPPacketSize)))
+	request = appendWorkerUInt32(request, uint32(len(options.patterns)))
+
+	for _, pattern := range options.patterns {
+		request = appendWorkerBytes(request, []byte(pattern))
+	}
+
+	if source.kind == imageSourceBytes {
+		return appendWorkerBytes(request, source.data)
+	}
+
+	return appendWorkerBytes(request, []byte(source.location))
+}
+
+func encodeWorkerResponse(buffer []byte, err error) []byte {
+	var response []byte
+
+	switch typedErr := err.(type) {
+	case nil:
+		response = []byte{workerStatusOK}
+
+	case *Error:
+		response = appendWorkerUInt32([]byte{workerStatusError}, uint32(int32(typedErr.Code)))
+		response = append(response, uint8(getErrorKindCode(typedErr.kind)))
+		response = appendWorkerBytes(response, []byte(typedErr.Message))
+
+	case *LimitError:
+		response = appendWorkerUInt32([]byte{workerStatusLimitError}, uint32(typedErr.code))
+
+	default:
+		response = appendWorkerUInt32([]byte{workerStatusError}, math.MaxUint32)
+		response = append(response, errorKindOther)
+		response = appendWorkerBytes(response, []byte(err.Error()))
+	}
+
+	return append(response, buffer...)
+}
+
+func readFrame(reader io.Reader) ([]byte, error) {
+	var frame []byte
+	var header [4]byte
+	var length uint32
+
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return nil, err
+	}
+
+	length = binary.LittleEndian.Uint32(header[:])
+
+	if length > maxFrameLength {
+		return nil, fmt.Errorf("frame length %d exceeds maximum of %d", length, maxFrameLength)
+	}
+
+	frame = make([]byte, length)
+
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+func writeFrame(writer io.Writer, payload []byte) error {
+	var _, err = writer.Write(appendWorkerBytes(make([]byte, 0, 4+len(payload)), payload))
+
+	return err
+}
@@ -0,0 +1,248 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+//
+// Public functions
+//
+
+// TestMain lets the test binary double as an isolated worker, see newTestIsolatedReader().
+func TestMain(m *testing.M) {
+	switch os.Getenv(testWorkerVariable) {
+	case "":
+		os.Exit(m.Run())
+
+	case testWorkerCrash:
+		// Exit without responding, like a worker crashing in Exiv2 would.
+
+		_, _ = readFrame(os.Stdin)
+
+		os.Exit(2)
+
+	case testWorkerCorrupt, testWorkerWarning:
+		serveTestResponses(os.Getenv(testWorkerVariable))
+
+	case testWorkerHang:
+		_, _ = readFrame(os.Stdin)
+
+		time.Sleep(time.Hour)
+
+	default:
+		if err := ServeIsolatedReads(os.Stdin, os.Stdout); err != nil {
+			os.Exit(1)
+		}
+	}
+
+	os.Exit(0)
+}
+
+func TestIsolatedRead(t *testing.T) {
+	var collection, isolatedCollection Collection
+	var data = newTestImage(0).JPEG()
+	var err error
+	var reader = newTestIsolatedReader(t, testWorkerServe, IsolatedReaderConfig{})
+
+	collection, err = FromBytes(data)
+
+	require.NoError(t, err)
+
+	isolatedCollection, err = FromBytes(data, Isolated(reader))
+
+	require.NoError(t, err)
+	require.True(t, Diff(collection, isolatedCollection).Empty())
+
+	// Errors are reported the same way as when reading in process.
+
+	_, err = FromFile("missing.jpg", Isolated(reader))
+
+	require.True(t, errors.Is(err, ErrNotFound), "unexpected error: %v", err)
+}
+
+func TestIsolatedReadCrash(t *testing.T) {
+	var err error
+	var reader = newTestIsolatedReader(t, testWorkerCrash, IsolatedReaderConfig{Workers: 1})
+
+	_, err = FromFile("missing.jpg", Isolated(reader))
+
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "isolated worker failed")
+
+	// The crashed worker is restarted when it's needed again.
+
+	t.Setenv(testWorkerVariable, testWorkerServe)
+
+	_, err = FromFile("missing.jpg", Isolated(reader))
+
+	require.True(t, errors.Is(err, ErrNotFound), "unexpected error: %v", err)
+}
+
+func TestIsolatedReadTimeout(t *testing.T) {
+	var err error
+	var reader = newTestIsolatedReader(t, testWorkerHang, IsolatedReaderConfig{
+		Timeout: 100 * time.Millisecond,
+		Workers: 1,
+	})
+
+	_, err = FromFile("missing.jpg", Isolated(reader))
+
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "timed out")
+}
+
+func TestIsolatedReaderClosed(t *testing.T) {
+	var err error
+	var reader = newTestIsolatedReader(t, testWorkerServe, IsolatedReaderConfig{})
+
+	require.NoError(t, reader.Close())
+
+	_, err = FromFile("missing.jpg", Isolated(reader))
+
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "closed")
+}
+
+func TestWorkerProtocol(t *testing.T) {
+	var buffer []byte
+	var decodedOptions *readOptions
+	var decodedSource *imageSource
+	var err error
+	var options = newReadOptions([]ReadOption{
+		Lenient(),
+		Limits(ReadLimits{MaxProperties: 1, MaxTotalBytes: 2, MaxValuesPerProperty: 3, MaxXMPPacketSize: 4}),
+		OnlyKeys("Exif.Photo.*", "Xmp.xmp.Rating"),
+	})
+
+	for _, source := range []*imageSource{
+		{data: []byte("image"), kind: imageSourceBytes},
+		{kind: imageSourceFile, location: "image.jpg"},
+		{kind: imageSourceURL, location: "https://example.com/image.jpg"},
+	} {
+		decodedSource, decodedOptions, err = decodeWorkerRequest(encodeWorkerRequest(source, options))
+
+		require.NoError(t, err)
+		require.Equal(t, source, decodedSource)
+		require.True(t, decodedOptions.lenient)
+		require.Equal(t, options.limits, decodedOptions.limits)
+		require.Equal(t, options.patterns, decodedOptions.patterns)
+	}
+
+	_, _, err = decodeWorkerRequest(encodeWorkerRequest(&imageSource{kind: imageSourceFile, location: "image.jpg"},
+		options)[:20])
+
+	require.Error(t, err)
+
+	// A response may hold both an error and a buffer when reading leniently.
+
+	buffer, err = decodeWorkerResponse(encodeWorkerResponse([]byte("buffer"), nil), options)
+
+	require.NoError(t, err)
+	require.Equal(t, []byte("buffer"), buffer)
+
+	buffer, err = decodeWorkerResponse(encodeWorkerResponse([]byte("buffer"), newError(9, errorKindNotFound,
+		"missing")), options)
+
+	require.Equal(t, []byte("buffer"), buffer)
+	require.True(t, errors.Is(err, ErrNotFound))
+	require.Equal(t, 9, err.(*Error).Code)
+	require.Equal(t, "missing", err.(*Error).Message)
+
+	buffer, err = decodeWorkerResponse(encodeWorkerResponse(nil, newLimitError(limitCodeTotalBytes,
+		&options.limits)), options)
+
+	require.Nil(t, buffer)
+	require.True(t, errors.Is(err, ErrLimitExceeded))
+	require.Equal(t, &LimitError{Limit: "MaxTotalBytes", Value: 2, code: limitCodeTotalBytes}, err)
+
+	_, err = decodeWorkerResponse(encodeWorkerResponse(nil, errors.New("failure")), options)
+
+	require.Error(t, err)
+	require.Equal(t, "failure", err.(*Error).Message)
+}
+
+//
+// Private constants
+//
+
+// The value of testWorkerVariable determines how the test binary behaves as an isolated worker.
+const (
+	testWorkerCorrupt  = "corrupt"
+	testWorkerCrash    = "crash"
+	testWorkerHang     = "hang"
+	testWorkerServe    = "serve"
+	testWorkerVariable = "EZIF_TEST_WORKER"
+	testWorkerWarning  = "warning"
+)
+
+//
+// Private functions
+//
+
+// serveTestResponses responds to every request with the metadata of newTestBuffer() followed by an info message and a
+// warning.  In corrupt mode, reading fails as if the image was corrupt, so the metadata is only returned when reading
+// leniently, as Exiv2 would.
+func serveTestResponses(mode string) {
+	var buffer = newTestBuffer()
+	var corruptErr = newError(0, errorKindCorrupt, "corrupt")
+
+	buffer.logMessage(uint8(SeverityInfo), "info")
+	buffer.logMessage(uint8(SeverityWarning), "warning")
+
+	for {
+		var options *readOptions
+		var request, response []byte
+		var err error
+
+		if request, err = readFrame(os.Stdin); err != nil {
+			return
+		}
+
+		if _, options, err = decodeWorkerRequest(request); err != nil {
+			return
+		}
+
+		switch {
+		case mode == testWorkerWarning:
+			response = encodeWorkerResponse(buffer.data, nil)
+
+		case options.lenient:
+			response = encodeWorkerResponse(buffer.data, corruptErr)
+
+		default:
+			response = encodeWorkerResponse(nil, corruptErr)
+		}
+
+		if err = writeFrame(os.Stdout, response); err != nil {
+			return
+		}
+	}
+}
+
+// newTestIsolatedReader creates an IsolatedReader whose workers run the test binary itself, behaving as indicated by
+// mode.  Workers are started as they're needed, so changing the mode only affects workers started afterwards.
+func newTestIsolatedReader(t *testing.T, mode string, config IsolatedReaderConfig) *IsolatedReader {
+	var err error
+	var reader *IsolatedReader
+
+	t.Setenv(testWorkerVariable, mode)
+
+	if config.WorkerPath, err = os.Executable(); err != nil {
+		t.Skipf("cannot find test binary: %v", err)
+	}
+
+	reader, err = NewIsolatedReader(config)
+
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = reader.Close()
+	})
+
+	return reader
+}
@@ -0,0 +1,553 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+// A Collection is marshaled to JSON using the following schema, which is versioned so that cached collections can be
+// told apart from those produced by an incompatible version of ezif:
+//
+//   {
+//     "version": 2,
+//     "exif": <properties>, "iptc": <properties>, "xmp": <properties>,
+//     "warnings": [{"message": "...", "severity": 2}, ...]
+//   }
+//
+// where <properties> is {"byteOrder": "big" | "little", "fileOrder": [<key>, ...], "properties": [<property>, ...]}
+// with properties in key order and fileOrder holding their keys in the order in which they were found in the image,
+// and <property> is:
+//
+//   {
+//     "key": "Exif.Image.Make", "family": "Exif", "group": "Image", "tag": "Make", "tagNumber": 271, "typeId": 2,
+//     "label": "Make", "interpreted": "Canon", "values": [...], "rawBytes": [...]
+//   }
+//
+// Severities are Exiv2::LogMsg::Level values and type IDs are types.ID values.  tagNumber is omitted for XMP properties,
+// fileOrder is omitted when there are no properties and rawBytes is only present for IPTC strings, and holds base64 encoded strings.  values holds the value of the
+// property in a form that depends on its type:
+//
+//   strings, comments, XMP text/arrays: array of strings
+//   XMP language alternatives:          array containing a single object mapping languages to strings
+//   integers:                           array of numbers
+//   floating point numbers:             array of numbers, or the strings "NaN", "+Inf" and "-Inf"
+//   rationals:                          array of "numerator/denominator" strings
+//   bytes and undefined values:         base64 encoded string
+//   IPTC dates:                         array of {"year", "month", "day"} objects
+//   IPTC times:                         array of {"hour", "minute", "second", "offsetHours", "offsetMinutes"} objects
+//   anything else:                      null
+
+//
+// Public functions
+//
+
+// FromJSON reconstructs a Collection from its JSON representation, as produced by marshaling a Collection with
+// encoding/json.  The order in which properties were found in the image (see Properties.AllInFileOrder()) is preserved,
+// except for collections marshaled using version 1 of the schema, which lacks it, in which case key order is used.
+func FromJSON(data []byte) (Collection, error) {
+	var collection = newCollection()
+
+	if err := collection.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+//
+// Private types
+//
+
+// Collection implementation (JSON)
+
+// MarshalJSON implements json.Marshaler.  Values that were read lazily are decoded in the process.
+func (collection *collectionImpl) MarshalJSON() ([]byte, error) {
+	var err error
+	var result = jsonCollection{
+		Version:  jsonVersion,
+		Warnings: make([]jsonWarning, len(collection.warnings)),
+	}
+
+	for i, warning := range collection.warnings {
+		result.Warnings[i] = jsonWarning{
+			Message:  warning.Message,
+			Severity: warning.Severity,
+		}
+	}
+
+	if result.Exif, err = marshalProperties(collection.exifProperties); err != nil {
+		return nil, err
+	}
+
+	if result.IPTC, err = marshalProperties(collection.iptcProperties); err != nil {
+		return nil, err
+	}
+
+	if result.XMP, err = marshalProperties(collection.xmpProperties); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&result)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (collection *collectionImpl) UnmarshalJSON(data []byte) error {
+	var err error
+	var source jsonCollection
+
+	if err = json.Unmarshal(data, &source); err != nil {
+		return err
+	}
+
+	// Version 1 only lacks tag numbers, which are then left as 0, and file order (see unmarshalProperties()).
+
+	if source.Version < jsonMinVersion || source.Version > jsonVersion {
+		return fmt.Errorf("unsupported collection JSON version %d", source.Version)
+	}
+
+	*collection = *newCollection()
+
+	for _, warning := range source.Warnings {
+		collection.warnings = append(collection.warnings, Warning{
+			Message:  warning.Message,
+			Severity: warning.Severity,
+		})
+	}
+
+	if err = unmarshalProperties(source.Exif, FamilyExif, collection.exifProperties); err != nil {
+		return err
+	}
+
+	if err = unmarshalProperties(source.IPTC, FamilyIPTC, collection.iptcProperties); err != nil {
+		return err
+	}
+
+	return unmarshalProperties(source.XMP, FamilyXMP, collection.xmpProperties)
+}
+
+// JSON schema
+type jsonCollection struct {
+	Version  int             `json:"version"`
+	Exif     *jsonProperties `json:"exif"`
+	IPTC     *jsonProperties `json:"iptc"`
+	XMP      *jsonProperties `json:"xmp"`
+	Warnings []jsonWarning   `json:"warnings"`
+}
+
+// jsonFloat is a floating point number that can be marshaled to JSON even if it isn't finite.
+type jsonFloat float64
+
+func (value jsonFloat) MarshalJSON() ([]byte, error) {
+	switch {
+	case math.IsNaN(float64(value)):
+		return []byte(`"NaN"`), nil
+
+	case math.IsInf(float64(value), 1):
+		return []byte(`"+Inf"`), nil
+
+	case math.IsInf(float64(value), -1):
+		return []byte(`"-Inf"`), nil
+	}
+
+	return []byte(strconv.FormatFloat(float64(value), 'g', -1, 64)), nil
+}
+
+func (value *jsonFloat) UnmarshalJSON(data []byte) error {
+	var number float64
+	var str string
+
+	if err := json.Unmarshal(data, &str); err == nil {
+		if number, err = strconv.ParseFloat(str, 64); err != nil {
+			return err
+		}
+	} else if err := json.Unmarshal(data, &number); err != nil {
+		return err
+	}
+
+	*value = jsonFloat(number)
+
+	return nil
+}
+
+type jsonIPTCDate struct {
+	Year  int `json:"year"`
+	Month int `json:"month"`
+	Day   int `json:"day"`
+}
+
+type jsonIPTCTime struct {
+	Hour          int `json:"hour"`
+	Minute        int `json:"minute"`
+	Second        int `json:"second"`
+	OffsetHours   int `json:"offsetHours"`
+	OffsetMinutes int `json:"offsetMinutes"`
+}
+
+type jsonProperties struct {
+	ByteOrder  string          `json:"byteOrder"`
+	FileOrder  []string        `json:"fileOrder,omitempty"`
+	Properties []*jsonProperty `json:"properties"`
+}
+
+type jsonProperty struct {
+	Key         string          `json:"key"`
+	Family      Family          `json:"family"`
+	Group       string          `json:"group"`
+	Tag         string          `json:"tag"`
+	TagNumber   uint16          `json:"tagNumber,omitempty"`
+	TypeID      types.ID        `json:"typeId"`
+	Label       string          `json:"label"`
+	Interpreted string          `json:"interpreted"`
+	Values      json.RawMessage `json:"values"`
+	RawBytes    [][]byte        `json:"rawBytes,omitempty"`
+}
+
+type jsonWarning struct {
+	Message  string   `json:"message"`
+	Severity Severity `json:"severity"`
+}
+
+//
+// Private constants
+//
+
+const (
+	jsonByteOrderBig    = "big"
+	jsonByteOrderLittle = "little"
+	jsonMinVersion      = 1
+	jsonVersion         = 2
+)
+
+//
+// Private functions
+//
+
+func marshalProperties(properties *propertiesImpl) (*jsonProperties, error) {
+	var result = &jsonProperties{
+		ByteOrder:  jsonByteOrderBig,
+		FileOrder:  properties.fileOrder,
+		Properties: make([]*jsonProperty, len(properties.keys)),
+	}
+
+	if properties.byteOrder == binary.LittleEndian {
+		result.ByteOrder = jsonByteOrderLittle
+	}
+
+	for i, key := range properties.keys {
+		var err error
+		var property = properties.propertyMap[key]
+
+		result.Properties[i] = &jsonProperty{
+			Key:         key,
+			Family:      property.family,
+			Group:       property.groupName,
+			Tag:         property.tagName,
+			TagNumber:   property.tagNumber,
+			TypeID:      property.typeId,
+			Label:       property.label,
+			Interpreted: property.InterpretedValue(),
+			RawBytes:    property.rawBytes,
+		}
+
+		if result.Properties[i].Values, err = marshalValues(property.Value()); err != nil {
+			return nil, fmt.Errorf("could not marshal values of metadata property '%s': %v", key, err)
+		}
+	}
+
+	return result, nil
+}
+
+func marshalValues(value interface{}) (json.RawMessage, error) {
+	switch value := value.(type) {
+	case []*big.Rat:
+		var slice = make([]string, len(value))
+
+		for i, rat := range value {
+			slice[i] = rat.Num().String() + "/" + rat.Denom().String()
+		}
+
+		return json.Marshal(slice)
+
+	case []float32:
+		var slice = make([]jsonFloat, len(value))
+
+		for i, number := range value {
+			slice[i] = jsonFloat(number)
+		}
+
+		return json.Marshal(slice)
+
+	case []float64:
+		var slice = make([]jsonFloat, len(value))
+
+		for i, number := range value {
+			slice[i] = jsonFloat(number)
+		}
+
+		return json.Marshal(slice)
+
+	case []types.IPTCDate:
+		var slice = make([]jsonIPTCDate, len(value))
+
+		for i, date := range value {
+			slice[i] = jsonIPTCDate{
+				Day:   date.Day(),
+				Month: int(date.Month()),
+				Year:  date.Year(),
+			}
+		}
+
+		return json.Marshal(slice)
+
+	case []types.IPTCTime:
+		var slice = make([]jsonIPTCTime, len(value))
+
+		for i, iptcTime := range value {
+			var _, offset = time.Date(0, time.January, 1, 0, 0, 0, 0, iptcTime.Timezone()).Zone()
+
+			slice[i] = jsonIPTCTime{
+				Hour:          iptcTime.Hour(),
+				Minute:        iptcTime.Minute(),
+				OffsetHours:   offset / 3600,
+				OffsetMinutes: (offset % 3600) / 60,
+				Second:        iptcTime.Second(),
+			}
+		}
+
+		return json.Marshal(slice)
+	}
+
+	// Everything else (strings, language alternatives, integers and bytes) is handled just fine by encoding/json.
+
+	return json.Marshal(value)
+}
+
+func unmarshalProperties(source *jsonProperties, family Family, properties *propertiesImpl) error {
+	if source == nil {
+		return nil
+	}
+
+	switch source.ByteOrder {
+	case jsonByteOrderBig:
+		properties.byteOrder = binary.BigEndian
+
+	case jsonByteOrderLittle:
+		properties.byteOrder = binary.LittleEndian
+
+	default:
+		return fmt.Errorf("unknown byte order '%s' for metadata family %s", source.ByteOrder, family)
+	}
+
+	for _, jsonProperty := range source.Properties {
+		var err error
+		var property *propertyImpl
+
+		if jsonProperty.Family != family {
+			return fmt.Errorf("metadata property '%s' of family %s found among %s properties", jsonProperty.Key,
+				jsonProperty.Family, family)
+		}
+
+		property = newProperty(family, jsonProperty.Group, jsonProperty.Tag, jsonProperty.TypeID, jsonProperty.Label,
+			jsonProperty.Interpreted, false)
+
+		if jsonProperty.Key != "" && jsonProperty.Key != property.key() {
+			return fmt.Errorf("metadata property key '%s' doesn't match its family, group and tag ('%s')",
+				jsonProperty.Key, property.key())
+		}
+
+		property.rawBytes = jsonProperty.RawBytes
+		property.tagNumber = jsonProperty.TagNumber
+
+		if property.value, err = unmarshalValues(property.typeId, jsonProperty.Values); err != nil {
+			return fmt.Errorf("could not unmarshal values of metadata property '%s': %v", property.key(), err)
+		}
+
+		properties.add(property)
+	}
+
+	// Properties are added in key order, so the file order has to be restored afterwards.  It's only missing from
+	// version 1 of the schema, in which case we'll stick to key order.
+
+	if source.FileOrder != nil {
+		var found = make(map[string]bool, len(source.FileOrder))
+
+		for _, key := range source.FileOrder {
+			if _, ok := properties.propertyMap[key]; !ok || found[key] {
+				return fmt.Errorf("file order of metadata family %s doesn't match its properties", family)
+			}
+
+			found[key] = true
+		}
+
+		if len(found) != len(properties.propertyMap) {
+			return fmt.Errorf("file order of metadata family %s doesn't match its properties", family)
+		}
+
+		properties.fileOrder = append([]string(nil), source.FileOrder...)
+	}
+
+	properties.finish()
+
+	return nil
+}
+
+func unmarshalValues(typeId types.ID, data json.RawMessage) (interface{}, error) {
+	var err error
+
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+
+	switch typeId {
+	case types.IDAsciiString, types.IDComment, types.IDIPTCString, types.IDXMPAlt, types.IDXMPBag, types.IDXMPSeq,
+		types.IDXMPText:
+		var slice []string
+
+		err = json.Unmarshal(data, &slice)
+
+		return slice, err
+
+	case types.IDIPTCDate:
+		var dates []jsonIPTCDate
+		var slice []types.IPTCDate
+
+		if err = json.Unmarshal(data, &dates); err != nil {
+			return nil, err
+		}
+
+		slice = make([]types.IPTCDate, len(dates))
+
+		for i, date := range dates {
+			slice[i] = types.NewIPTCDate(date.Year, time.Month(date.Month), date.Day)
+		}
+
+		return slice, nil
+
+	case types.IDIPTCTime:
+		var slice []types.IPTCTime
+		var times []jsonIPTCTime
+
+		if err = json.Unmarshal(data, &times); err != nil {
+			return nil, err
+		}
+
+		slice = make([]types.IPTCTime, len(times))
+
+		for i, iptcTime := range times {
+			slice[i] = types.NewIPTCTime(iptcTime.Hour, iptcTime.Minute, iptcTime.Second, iptcTime.OffsetHours,
+				iptcTime.OffsetMinutes)
+		}
+
+		return slice, nil
+
+	case types.IDSignedByte:
+		var slice []int8
+
+		err = json.Unmarshal(data, &slice)
+
+		return slice, err
+
+	case types.IDSignedLong:
+		var slice []int32
+
+		err = json.Unmarshal(data, &slice)
+
+		return slice, err
+
+	case types.IDSignedRational, types.IDUnsignedRational:
+		var slice []*big.Rat
+		var strs []string
+
+		if err = json.Unmarshal(data, &strs); err != nil {
+			return nil, err
+		}
+
+		slice = make([]*big.Rat, len(strs))
+
+		for i, str := range strs {
+			var ok bool
+
+			if slice[i], ok = new(big.Rat).SetString(str); !ok {
+				return nil, fmt.Errorf("invalid rational value '%s'", str)
+			}
+		}
+
+		return slice, nil
+
+	case types.IDSignedShort:
+		var slice []int16
+
+		err = json.Unmarshal(data, &slice)
+
+		return slice, err
+
+	case types.IDTIFFDouble:
+		var numbers []jsonFloat
+		var slice []float64
+
+		if err = json.Unmarshal(data, &numbers); err != nil {
+			return nil, err
+		}
+
+		slice = make([]float64, len(numbers))
+
+		for i, number := range numbers {
+			slice[i] = float64(number)
+		}
+
+		return slice, nil
+
+	case types.IDTIFFFloat:
+		var numbers []jsonFloat
+		var slice []float32
+
+		if err = json.Unmarshal(data, &numbers); err != nil {
+			return nil, err
+		}
+
+		slice = make([]float32, len(numbers))
+
+		for i, number := range numbers {
+			slice[i] = float32(number)
+		}
+
+		return slice, nil
+
+	case types.IDUndefined, types.IDUnsignedByte:
+		var slice []byte
+
+		err = json.Unmarshal(data, &slice)
+
+		return slice, err
+
+	case types.IDUnsignedLong:
+		var slice []uint32
+
+		err = json.Unmarshal(data, &slice)
+
+		return slice, err
+
+	case types.IDUnsignedShort:
+		var slice []uint16
+
+		err = json.Unmarshal(data, &slice)
+
+		return slice, err
+
+	case types.IDXMPLangAlt:
+		var slice []map[string]string
+
+		err = json.Unmarshal(data, &slice)
+
+		return slice, err
+	}
+
+	return nil, fmt.Errorf("unsupported type %s", typeId)
+}
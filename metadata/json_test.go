@@ -0,0 +1,199 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+//
+// Public functions
+//
+
+func TestFromJSONErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"ByteOrder": `{"version": 2, "exif": {"byteOrder": "middle", "properties": []}}`,
+		"Family": `{"version": 2, "exif": {"byteOrder": "little", "properties": [{"key": "Xmp.dc.title",
+			"family": "Xmp", "group": "dc", "tag": "title", "typeId": 65536, "values": ["Title"]}]}}`,
+		"FileOrder": `{"version": 2, "exif": {"byteOrder": "little", "fileOrder": ["Exif.Image.Model"],
+			"properties": [{"key": "Exif.Image.Make", "family": "Exif", "group": "Image", "tag": "Make", "typeId": 2,
+			"values": ["Canon"]}]}}`,
+		"FileOrderDuplicate": `{"version": 2, "exif": {"byteOrder": "little",
+			"fileOrder": ["Exif.Image.Make", "Exif.Image.Make"], "properties": [{"key": "Exif.Image.Make",
+			"family": "Exif", "group": "Image", "tag": "Make", "typeId": 2, "values": ["Canon"]}]}}`,
+		"Key": `{"version": 2, "exif": {"byteOrder": "little", "properties": [{"key": "Exif.Image.Model",
+			"family": "Exif", "group": "Image", "tag": "Make", "typeId": 2, "values": ["Canon"]}]}}`,
+		"Rational": `{"version": 2, "exif": {"byteOrder": "little", "properties": [{"key": "Exif.Photo.FNumber",
+			"family": "Exif", "group": "Photo", "tag": "FNumber", "typeId": 5, "values": ["2.8/"]}]}}`,
+		"Syntax": `{"version": 2, "exif": `,
+		"Values": `{"version": 2, "exif": {"byteOrder": "little", "properties": [{"key": "Exif.Image.Make",
+			"family": "Exif", "group": "Image", "tag": "Make", "typeId": 2, "values": [1, 2]}]}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var collection, err = FromJSON([]byte(data))
+
+			require.Error(t, err)
+			require.Nil(t, collection)
+		})
+	}
+}
+
+func TestFromJSONVersions(t *testing.T) {
+	var tests = []struct {
+		name      string
+		tagNumber string
+		version   int
+		wantErr   bool
+		wantTag   uint16
+	}{
+		{
+			name:    "Version1",
+			version: 1,
+		},
+		{
+			name:      "Version2",
+			tagNumber: `"tagNumber": 271, `,
+			version:   2,
+			wantTag:   271,
+		},
+		{
+			name:    "Unsupported",
+			version: 3,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var collection, err = FromJSON([]byte(fmt.Sprintf(`{"version": %d, "exif": {"byteOrder": "little",
+				"properties": [{"key": "Exif.Image.Make", "family": "Exif", "group": "Image", "tag": "Make", %s"typeId": 2,
+				"values": ["Canon"]}]}}`, test.version, test.tagNumber)))
+			var data []byte
+			var roundTrip Collection
+
+			if test.wantErr {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.wantTag, collection.Exif().Get("Exif.Image.Make").TagNumber())
+			require.Equal(t, []string{"Canon"}, collection.Exif().Get("Exif.Image.Make").Value())
+
+			// Collections are always marshaled using the current version.
+
+			data, err = json.Marshal(collection)
+
+			require.NoError(t, err)
+			require.Contains(t, string(data), fmt.Sprintf(`"version":%d`, jsonVersion))
+
+			roundTrip, err = FromJSON(data)
+
+			require.NoError(t, err)
+			require.Equal(t, test.wantTag, roundTrip.Exif().Get("Exif.Image.Make").TagNumber())
+		})
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	var buffer = newTestBuffer()
+	var collection *collectionImpl
+	var data []byte
+	var err error
+	var roundTrip Collection
+
+	buffer.logMessage(uint8(SeverityWarning), "warning")
+
+	collection, err = decodeCollection(buffer.data, newReadOptions(nil))
+
+	require.NoError(t, err)
+
+	addJSONTestProperties(collection.exifProperties)
+
+	data, err = json.Marshal(collection)
+
+	require.NoError(t, err)
+
+	roundTrip, err = FromJSON(data)
+
+	require.NoError(t, err)
+	require.True(t, Diff(collection, roundTrip).Empty(), "%+v", Diff(collection, roundTrip))
+	require.Equal(t, binary.BigEndian, roundTrip.Exif().ByteOrder())
+	require.Equal(t, collection.Warnings(), roundTrip.Warnings())
+
+	for key, property := range collection.All() {
+		var roundTripProperty = getProperty(roundTrip, key)
+
+		require.Equal(t, property.TypeID(), roundTripProperty.TypeID(), key)
+		require.Equal(t, property.TagNumber(), roundTripProperty.TagNumber(), key)
+		require.Equal(t, property.Label(), roundTripProperty.Label(), key)
+		require.Equal(t, property.InterpretedValue(), roundTripProperty.InterpretedValue(), key)
+		require.Equal(t, property.RawBytes(), roundTripProperty.RawBytes(), key)
+	}
+
+	// Properties are found in a different order than their keys, and both orders survive the round trip.
+
+	require.NotEqual(t, collection.Exif().Keys(), collection.Exif().KeysInFileOrder())
+
+	for family, properties := range map[Family][2]Properties{
+		FamilyExif: {collection.Exif(), roundTrip.Exif()},
+		FamilyIPTC: {collection.IPTC(), roundTrip.IPTC()},
+		FamilyXMP:  {collection.XMP(), roundTrip.XMP()},
+	} {
+		require.Equal(t, properties[0].Keys(), properties[1].Keys(), family)
+		require.Equal(t, properties[0].KeysInFileOrder(), properties[1].KeysInFileOrder(), family)
+		require.Equal(t, properties[0].KeysInTagOrder(), properties[1].KeysInTagOrder(), family)
+	}
+
+	// Lazily read values are decoded when marshaling.
+
+	collection, err = decodeCollection(buffer.data, newReadOptions([]ReadOption{Lazy()}))
+
+	require.NoError(t, err)
+
+	data, err = json.Marshal(collection)
+
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"14/5"`)
+}
+
+//
+// Private functions
+//
+
+// addJSONTestProperties adds a property of every type that isn't found in newTestBuffer(), including values that JSON
+// can't represent directly.
+func addJSONTestProperties(properties *propertiesImpl) {
+	for _, test := range []struct {
+		tagName string
+		typeId  types.ID
+		value   interface{}
+	}{
+		{tagName: "Bytes", typeId: types.IDUnsignedByte, value: []byte{0, 1, 255}},
+		{tagName: "Double", typeId: types.IDTIFFDouble, value: []float64{1.5, math.NaN(), math.Inf(1), math.Inf(-1)}},
+		{tagName: "Float", typeId: types.IDTIFFFloat, value: []float32{0.25, float32(math.Inf(-1))}},
+		{tagName: "SignedByte", typeId: types.IDSignedByte, value: []int8{-128, 127}},
+		{tagName: "SignedLong", typeId: types.IDSignedLong, value: []int32{math.MinInt32}},
+		{tagName: "SignedRational", typeId: types.IDSignedRational, value: []*big.Rat{big.NewRat(-1, 3)}},
+		{tagName: "SignedShort", typeId: types.IDSignedShort, value: []int16{-1}},
+		{tagName: "Time", typeId: types.IDIPTCTime, value: []types.IPTCTime{types.NewIPTCTime(15, 9, 26, 5, 30)}},
+		{tagName: "Undefined", typeId: types.IDUndefined, value: []byte("ASCII\x00\x00\x00")},
+		{tagName: "UnsignedLong", typeId: types.IDUnsignedLong, value: []uint32{math.MaxUint32}},
+	} {
+		var property = newProperty(FamilyExif, "Test", test.tagName, test.typeId, test.tagName, "", false)
+
+		property.value = test.value
+
+		properties.add(property)
+	}
+
+	properties.finish()
+}
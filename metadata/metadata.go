@@ -1,8 +1,15 @@
 package metadata // import "golang.handcraftedbits.com/ezif/metadata"
 
 import (
-	"math/big"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"iter"
+	"log/slog"
+	"reflect"
 	"sort"
+	"sync"
+	"time"
 
 	"golang.handcraftedbits.com/ezif/types"
 )
@@ -13,24 +20,97 @@ import (
 
 type Family string
 
+// Collection is the metadata read from an image.  A Collection can be marshaled to JSON with encoding/json, and
+// reconstructed using FromJSON().
 type Collection interface {
+	json.Marshaler
+
+	// All returns an iterator over the Exif, IPTC and XMP properties (in that order) and their keys, with the
+	// properties of each family in key order.
+	All() iter.Seq2[string, Property]
+
+	// AllInFileOrder is like All, except that the properties of each family are in the order in which they were found
+	// in the image.
+	AllInFileOrder() iter.Seq2[string, Property]
 	Exif() Properties
 	IPTC() Properties
+
+	// Warnings returns the warnings reported by Exiv2 while reading the image, in the order in which they were
+	// reported.
+	Warnings() []Warning
 	XMP() Properties
 }
 
 type Properties interface {
+	// All returns an iterator over the properties and their keys, in key order.
+	All() iter.Seq2[string, Property]
+
+	// AllInFileOrder returns an iterator over the properties and their keys, in the order in which they were found in
+	// the image.
+	AllInFileOrder() iter.Seq2[string, Property]
+
+	// ByteOrder returns the byte order used to encode binary property values.  For Exif properties this is the byte
+	// order of the image, while IPTC (and XMP, which contains no binary values) properties are always big endian.
+	ByteOrder() binary.ByteOrder
 	Get(key string) Property
+
+	// Groups returns the sorted names of the groups (e.g., "Photo" for Exif.Photo.FNumber) the properties belong to.
+	Groups() []string
 	HasKey(key string) bool
+
+	// InGroup returns the properties belonging to the given group (e.g., "Photo").
+	InGroup(group string) Properties
 	Keys() []string
+
+	// KeysInFileOrder returns the keys of the properties in the order in which they were found in the image, which is
+	// the order AllInFileOrder iterates over them in.
+	KeysInFileOrder() []string
+
+	// KeysInTagOrder returns the keys of the properties ordered by group, in the order in which groups were found in
+	// the image (which, for Exif properties, follows the IFD layout), and then by tag number (see
+	// Property.TagNumber()).
+	KeysInTagOrder() []string
+
+	// Len returns the number of properties.
+	Len() int
+
+	// Match returns the properties whose keys match the given pattern, in which * matches any sequence of characters
+	// (including periods) and ? matches any single character (e.g., "Exif.Photo.*" or "Xmp.*.Rating").
+	Match(pattern string) Properties
 }
 
 type Property interface {
 	Family() Family
+
+	// Float64s returns the values of the property converted to floating point numbers (e.g., rationals are divided
+	// and strings are parsed).
+	Float64s() ([]float64, error)
 	GroupName() string
+
+	// Int64s returns the values of the property converted to integers.  An error is returned if a value isn't an
+	// integer (e.g., the rational 1/3) or can't be parsed as one.
+	Int64s() ([]int64, error)
 	InterpretedValue() string
 	Label() string
+
+	// RawBytes returns the undecoded bytes of each value of an IPTC string dataset as found in the image, before any
+	// character set conversion was applied.  For all other properties nil is returned.
+	RawBytes() [][]byte
+
+	// Strings returns the values of the property converted to strings (e.g., rationals are formatted as
+	// "numerator/denominator", or as an integer if the denominator is 1, XMP language alternatives are replaced by
+	// their default value and undefined values that are just text become a single string, like with Get()).
+	Strings() ([]string, error)
 	TagName() string
+
+	// TagNumber returns the Exif tag or IPTC dataset number of the property.  XMP properties have no tag number, so 0
+	// is returned for them.
+	TagNumber() uint16
+
+	// Time returns the first value of the property converted to a time: IPTC dates become midnight UTC on that date,
+	// IPTC times become that time on January 1 of year 0, and Exif and XMP date strings are parsed.  Use DateTime() to
+	// combine separate date and time properties.
+	Time() (time.Time, error)
 	TypeID() types.ID
 	Value() interface{}
 }
@@ -53,9 +133,18 @@ const (
 type collectionImpl struct {
 	exifProperties *propertiesImpl
 	iptcProperties *propertiesImpl
+	warnings       []Warning
 	xmpProperties  *propertiesImpl
 }
 
+func (collection *collectionImpl) All() iter.Seq2[string, Property] {
+	return collection.all((*propertiesImpl).All)
+}
+
+func (collection *collectionImpl) AllInFileOrder() iter.Seq2[string, Property] {
+	return collection.all((*propertiesImpl).AllInFileOrder)
+}
+
 func (collection *collectionImpl) Exif() Properties {
 	return collection.exifProperties
 }
@@ -64,226 +153,263 @@ func (collection *collectionImpl) IPTC() Properties {
 	return collection.iptcProperties
 }
 
+func (collection *collectionImpl) Warnings() []Warning {
+	return collection.warnings
+}
+
 func (collection *collectionImpl) XMP() Properties {
 	return collection.xmpProperties
 }
 
+func (collection *collectionImpl) all(
+	iterate func(properties *propertiesImpl) iter.Seq2[string, Property]) iter.Seq2[string, Property] {
+	return func(yield func(string, Property) bool) {
+		for _, properties := range []*propertiesImpl{collection.exifProperties, collection.iptcProperties,
+			collection.xmpProperties} {
+			for key, property := range iterate(properties) {
+				if !yield(key, property) {
+					return
+				}
+			}
+		}
+	}
+}
+
 // Properties implementation
 type propertiesImpl struct {
+	byteOrder   binary.ByteOrder
+	fileOrder   []string
 	propertyMap map[string]*propertyImpl
 	keys        []string
 }
 
-func (properties *propertiesImpl) Get(key string) Property {
-	return properties.propertyMap[key]
+func (properties *propertiesImpl) All() iter.Seq2[string, Property] {
+	return properties.all(properties.keys)
 }
 
-func (properties *propertiesImpl) HasKey(key string) bool {
-	if _, ok := properties.propertyMap[key]; ok {
-		return true
-	}
+func (properties *propertiesImpl) AllInFileOrder() iter.Seq2[string, Property] {
+	return properties.all(properties.KeysInFileOrder())
+}
 
-	return false
+func (properties *propertiesImpl) ByteOrder() binary.ByteOrder {
+	return properties.byteOrder
 }
 
-func (properties *propertiesImpl) Keys() []string {
-	return properties.keys
+func (properties *propertiesImpl) Get(key string) Property {
+	return properties.propertyMap[key]
 }
 
-func (properties *propertiesImpl) add(property *propertyImpl, values []interface{}) {
-	var oldProperty = properties.propertyMap[property.key()]
-	var valuesLength = len(values)
+func (properties *propertiesImpl) Groups() []string {
+	var result []string
 
-	// IPTC metadata properties can be "repeatable" (at this time, this only applies to dates and strings), meaning that
-	// the property can be defined multiple times and the values still need to be preserved.  Exif and XMP properties
-	// can be repeated multiple times but we only preserve the last value.  Therefore, if the metadata property is
-	// repeatable and it already exists we won't do anything here.  Later, we'll append the new value to the existing
-	// array value.
-
-	if property.repeatable {
-		if oldProperty == nil {
-			properties.propertyMap[property.key()] = property
-		} else {
-			property = oldProperty
-		}
+	// Keys are sorted, so properties belonging to the same group are next to each other.  Groups themselves may still
+	// be out of order though (e.g., "Xmp.mwg-rs" sorts before "Xmp.mwg").
 
-		// Decrement valuesLength because IPTC repeatable metadata property values come in one at a time and in the
-		// event that this is the first value we want to make a slice of length 0 to append into (otherwise the first
-		// element will be a zero value).
+	for _, key := range properties.keys {
+		var groupName = properties.propertyMap[key].groupName
 
-		valuesLength -= 1
-	} else {
-		properties.propertyMap[property.key()] = property
+		if len(result) == 0 || result[len(result)-1] != groupName {
+			result = append(result, groupName)
+		}
 	}
 
-	// We need to do a manual conversion from an interface{} slice to a concrete-typed slice.  This keeps us from having
-	// to do a similar conversion later on.
+	sort.Strings(result)
 
-	switch property.TypeID() {
-	case types.IDAsciiString, types.IDComment, types.IDXMPAlt, types.IDXMPBag, types.IDXMPSeq, types.IDXMPText:
-		var slice = make([]string, valuesLength)
+	return result
+}
 
-		for i, value := range values {
-			slice[i] = value.(string)
-		}
+func (properties *propertiesImpl) HasKey(key string) bool {
+	if _, ok := properties.propertyMap[key]; ok {
+		return true
+	}
 
-		property.value = slice
+	return false
+}
 
-	case types.IDIPTCDate:
-		var slice []types.IPTCDate
+func (properties *propertiesImpl) InGroup(group string) Properties {
+	return properties.filter(func(property *propertyImpl) bool {
+		return property.groupName == group
+	})
+}
 
-		if !property.repeatable || oldProperty == nil {
-			slice = make([]types.IPTCDate, valuesLength)
-		} else {
-			slice = property.value.([]types.IPTCDate)
-		}
+func (properties *propertiesImpl) Keys() []string {
+	return properties.keys
+}
 
-		for i, value := range values {
-			if !property.repeatable {
-				slice[i] = value.(types.IPTCDate)
-			} else {
-				slice = append(slice, value.(types.IPTCDate))
-			}
-		}
+func (properties *propertiesImpl) KeysInFileOrder() []string {
+	return properties.fileOrder
+}
 
-		property.value = slice
+func (properties *propertiesImpl) KeysInTagOrder() []string {
+	var groupOrder = make(map[string]int)
+	var result = make([]string, len(properties.fileOrder))
 
-	case types.IDIPTCString:
-		var slice []string
+	for _, key := range properties.fileOrder {
+		var groupName = properties.propertyMap[key].groupName
 
-		if !property.repeatable || oldProperty == nil {
-			slice = make([]string, valuesLength)
-		} else {
-			slice = property.value.([]string)
+		if _, ok := groupOrder[groupName]; !ok {
+			groupOrder[groupName] = len(groupOrder)
 		}
+	}
 
-		for i, value := range values {
-			if !property.repeatable {
-				slice[i] = value.(string)
-			} else {
-				slice = append(slice, value.(string))
-			}
-		}
+	copy(result, properties.fileOrder)
 
-		property.value = slice
+	// Properties with the same tag number (which is always the case for XMP properties) are kept in file order.
 
-	case types.IDIPTCTime:
-		var slice = make([]types.IPTCTime, valuesLength)
+	sort.SliceStable(result, func(i, j int) bool {
+		var property1 = properties.propertyMap[result[i]]
+		var property2 = properties.propertyMap[result[j]]
 
-		for i, value := range values {
-			slice[i] = value.(types.IPTCTime)
+		if property1.groupName != property2.groupName {
+			return groupOrder[property1.groupName] < groupOrder[property2.groupName]
 		}
 
-		property.value = slice
+		return property1.tagNumber < property2.tagNumber
+	})
 
-	case types.IDSignedByte:
-		var slice = make([]int8, valuesLength)
+	return result
+}
 
-		for i, value := range values {
-			slice[i] = value.(int8)
-		}
+func (properties *propertiesImpl) Len() int {
+	return len(properties.keys)
+}
 
-		property.value = slice
+func (properties *propertiesImpl) Match(pattern string) Properties {
+	return properties.filter(func(property *propertyImpl) bool {
+		return matchPattern(pattern, property.key())
+	})
+}
 
-	case types.IDSignedLong:
-		var slice = make([]int32, valuesLength)
+func (properties *propertiesImpl) add(property *propertyImpl) {
+	var oldProperty = properties.propertyMap[property.key()]
 
-		for i, value := range values {
-			slice[i] = value.(int32)
-		}
+	// IPTC metadata properties can be "repeatable" (at this time, this only applies to dates and strings), meaning that
+	// the property can be defined multiple times and the values still need to be preserved.  Exif and XMP properties
+	// can be repeated multiple times but we only preserve the last value.  Therefore, if the metadata property is
+	// repeatable and it already exists we'll append the new values to the existing ones.
 
-		property.value = slice
+	if property.repeatable && oldProperty != nil {
+		oldProperty.merge(property)
 
-	case types.IDSignedShort:
-		var slice = make([]int16, valuesLength)
+		return
+	}
 
-		for i, value := range values {
-			slice[i] = value.(int16)
-		}
+	// A property that replaces another one keeps its position.
 
-		property.value = slice
+	if oldProperty == nil {
+		properties.fileOrder = append(properties.fileOrder, property.key())
+	}
 
-	case types.IDSignedRational, types.IDUnsignedRational:
-		var slice = make([]*big.Rat, valuesLength)
+	properties.propertyMap[property.key()] = property
+}
 
-		for i, value := range values {
-			slice[i] = value.(*big.Rat)
+func (properties *propertiesImpl) all(keys []string) iter.Seq2[string, Property] {
+	return func(yield func(string, Property) bool) {
+		for _, key := range keys {
+			if !yield(key, properties.propertyMap[key]) {
+				return
+			}
 		}
+	}
+}
 
-		property.value = slice
+func (properties *propertiesImpl) decodeIPTCStrings(ctx context.Context, logger *slog.Logger) {
+	var allValues [][]byte
+	var charset iptcCharset
+	var declared []byte
+	var err error
 
-	case types.IDTIFFDouble:
-		var slice = make([]float64, valuesLength)
+	if property, ok := properties.propertyMap[iptcKeyCharacterSet]; ok && len(property.rawBytes) > 0 {
+		declared = property.rawBytes[0]
+	}
 
-		for i, value := range values {
-			slice[i] = value.(float64)
+	for _, property := range properties.propertyMap {
+		if property.typeId == types.IDIPTCString {
+			allValues = append(allValues, property.rawBytes...)
 		}
+	}
 
-		property.value = slice
+	if charset, err = detectIPTCCharset(declared, allValues); err != nil {
+		if logger.Enabled(ctx, slog.LevelWarn) {
+			logger.WarnContext(ctx, "could not use declared IPTC character set",
+				"charset", charset,
+				"error", err,
+			)
+		}
+	}
 
-	case types.IDTIFFFloat:
-		var slice = make([]float32, valuesLength)
+	if logger.Enabled(ctx, slog.LevelDebug) {
+		logger.DebugContext(ctx, "IPTC character set",
+			"charset", charset,
+			"declared", declared != nil,
+		)
+	}
 
-		for i, value := range values {
-			slice[i] = value.(float32)
+	for _, property := range properties.propertyMap {
+		if property.typeId != types.IDIPTCString {
+			continue
 		}
 
-		property.value = slice
-
-	case types.IDUndefined:
-		var slice = make([]byte, valuesLength)
+		var slice = make([]string, len(property.rawBytes))
 
-		for i, value := range values {
-			slice[i] = value.(byte)
+		for i, value := range property.rawBytes {
+			slice[i] = decodeIPTCString(value, charset)
 		}
 
-		property.value = slice
-
-	case types.IDUnsignedByte:
-		var slice = make([]uint8, valuesLength)
+		// The interpreted value of an IPTC string is just the (first) string itself, so it needs the same treatment.
 
-		for i, value := range values {
-			slice[i] = value.(uint8)
+		if len(slice) > 0 {
+			property.interpretedValue = slice[0]
 		}
 
 		property.value = slice
+	}
+}
 
-	case types.IDUnsignedLong:
-		var slice = make([]uint32, valuesLength)
-
-		for i, value := range values {
-			slice[i] = value.(uint32)
+func (properties *propertiesImpl) removeUnselected() {
+	for key, property := range properties.propertyMap {
+		if property.unselected {
+			delete(properties.propertyMap, key)
 		}
+	}
+}
 
-		property.value = slice
-
-	case types.IDUnsignedShort:
-		var slice = make([]uint16, valuesLength)
+// filter returns a copy of the properties containing only those for which the given function returns true.
+func (properties *propertiesImpl) filter(include func(property *propertyImpl) bool) *propertiesImpl {
+	var result = &propertiesImpl{
+		byteOrder:   properties.byteOrder,
+		propertyMap: make(map[string]*propertyImpl),
+	}
 
-		for i, value := range values {
-			slice[i] = value.(uint16)
+	for _, key := range properties.keys {
+		if property := properties.propertyMap[key]; include(property) {
+			result.keys = append(result.keys, key)
+			result.propertyMap[key] = property
 		}
+	}
 
-		property.value = slice
+	for _, key := range properties.fileOrder {
+		if _, ok := result.propertyMap[key]; ok {
+			result.fileOrder = append(result.fileOrder, key)
+		}
+	}
 
-	// XMPLangAlt is a special case, there's really only a single "value", which is a map.
+	return result
+}
 
-	case types.IDXMPLangAlt:
-		var langAlt = make(map[string]string)
+func (properties *propertiesImpl) finish() {
+	var fileOrder = properties.fileOrder[:0]
+	var i = 0
 
-		for _, value := range values {
-			curValue := value.(*xmpLangAltEntry)
+	// Properties may have been removed since they were added (see removeUnselected()).
 
-			langAlt[curValue.language] = curValue.value
+	for _, key := range properties.fileOrder {
+		if _, ok := properties.propertyMap[key]; ok {
+			fileOrder = append(fileOrder, key)
 		}
-
-		// TODO: can this be done as a single value instead of a slice?
-		property.value = []map[string]string{langAlt}
 	}
-}
 
-func (properties *propertiesImpl) finish() {
-	var i = 0
+	properties.fileOrder = fileOrder
 
 	properties.keys = make([]string, len(properties.propertyMap))
 
@@ -302,21 +428,54 @@ type propertyImpl struct {
 	groupName        string
 	interpretedValue string
 	label            string
+	rawBytes         [][]byte
 	repeatable       bool
 	tagName          string
+	tagNumber        uint16
 	typeId           types.ID
+	unselected       bool
 	value            interface{}
+
+	// Set when the property was read lazily, in which case the value and/or interpreted value are decoded from
+	// encodedValues on first access.
+	byteOrder                binary.ByteOrder
+	count                    int
+	deferredInterpretedValue bool
+	deferredValue            bool
+	encodedValues            []byte
+	interpretedValueOnce     sync.Once
+	logContext               context.Context
+	logger                   *slog.Logger
+	valueOnce                sync.Once
 }
 
 func (property *propertyImpl) Family() Family {
 	return property.family
 }
 
+func (property *propertyImpl) Float64s() ([]float64, error) {
+	var result []float64
+	var err = convertProperty(property, reflect.ValueOf(&result).Elem())
+
+	return result, err
+}
+
 func (property *propertyImpl) GroupName() string {
 	return property.groupName
 }
 
+func (property *propertyImpl) Int64s() ([]int64, error) {
+	var result []int64
+	var err = convertProperty(property, reflect.ValueOf(&result).Elem())
+
+	return result, err
+}
+
 func (property *propertyImpl) InterpretedValue() string {
+	if property.deferredInterpretedValue {
+		property.interpretedValueOnce.Do(property.formatDeferredInterpretedValue)
+	}
+
 	return property.interpretedValue
 }
 
@@ -324,18 +483,84 @@ func (property *propertyImpl) Label() string {
 	return property.label
 }
 
+func (property *propertyImpl) RawBytes() [][]byte {
+	return property.rawBytes
+}
+
+func (property *propertyImpl) Strings() ([]string, error) {
+	var result []string
+	var err = convertProperty(property, reflect.ValueOf(&result).Elem())
+
+	return result, err
+}
+
 func (property *propertyImpl) TagName() string {
 	return property.tagName
 }
 
+func (property *propertyImpl) TagNumber() uint16 {
+	return property.tagNumber
+}
+
+func (property *propertyImpl) Time() (time.Time, error) {
+	var result time.Time
+	var err = convertProperty(property, reflect.ValueOf(&result).Elem())
+
+	return result, err
+}
+
 func (property *propertyImpl) TypeID() types.ID {
 	return property.typeId
 }
 
 func (property *propertyImpl) Value() interface{} {
+	if property.deferredValue {
+		property.valueOnce.Do(property.decodeDeferredValue)
+	}
+
 	return property.value
 }
 
+func (property *propertyImpl) decodeDeferredValue() {
+	if err := decodeValues(property, property.count, property.encodedValues, property.byteOrder); err != nil {
+		if property.logger.Enabled(property.logContext, slog.LevelWarn) {
+			property.logger.WarnContext(property.logContext, "could not decode deferred property value",
+				"error", err,
+				"name", property.key(),
+			)
+		}
+	}
+}
+
+func (property *propertyImpl) formatDeferredInterpretedValue() {
+	var err error
+
+	if property.interpretedValue, err = formatExifInterpretedValue(property); err != nil {
+		if property.logger.Enabled(property.logContext, slog.LevelWarn) {
+			property.logger.WarnContext(property.logContext, "could not format deferred interpreted value",
+				"error", err,
+				"name", property.key(),
+			)
+		}
+	}
+}
+
+func (property *propertyImpl) merge(other *propertyImpl) {
+	property.rawBytes = append(property.rawBytes, other.rawBytes...)
+
+	switch value := property.value.(type) {
+	case []types.IPTCDate:
+		if otherValue, ok := other.value.([]types.IPTCDate); ok {
+			property.value = append(value, otherValue...)
+		}
+
+	case []types.IPTCTime:
+		if otherValue, ok := other.value.([]types.IPTCTime); ok {
+			property.value = append(value, otherValue...)
+		}
+	}
+}
+
 func (property *propertyImpl) key() string {
 	return string(property.family) + "." + property.groupName + "." + property.tagName
 }
@@ -349,6 +574,48 @@ type xmpLangAltEntry struct {
 // Private functions
 //
 
+// matchPattern reports whether a key matches a pattern in which * matches any sequence of characters and ? matches any
+// single character.  This must behave like matchPattern() in exiv2_read.cpp, which is used by the OnlyKeys() option.
+func matchPattern(pattern, key string) bool {
+	var backtrackKey, backtrackPattern = -1, -1
+	var k, p = 0, 0
+
+	for k < len(key) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			backtrackPattern = p
+			backtrackKey = k
+			p++
+
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == key[k]):
+			k++
+			p++
+
+		case backtrackPattern != -1:
+			backtrackKey++
+			k = backtrackKey
+			p = backtrackPattern + 1
+
+		default:
+			return false
+		}
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
+
+func newCollection() *collectionImpl {
+	return &collectionImpl{
+		exifProperties: &propertiesImpl{byteOrder: binary.LittleEndian, propertyMap: make(map[string]*propertyImpl)},
+		iptcProperties: &propertiesImpl{byteOrder: binary.BigEndian, propertyMap: make(map[string]*propertyImpl)},
+		xmpProperties:  &propertiesImpl{byteOrder: binary.BigEndian, propertyMap: make(map[string]*propertyImpl)},
+	}
+}
+
 func newProperty(family Family, groupName, tagName string, typeId types.ID, label, interpretedValue string,
 	repeatable bool) *propertyImpl {
 	return &propertyImpl{
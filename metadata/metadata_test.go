@@ -0,0 +1,192 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+//
+// Public functions
+//
+
+func TestCollectionAll(t *testing.T) {
+	var collection, err = decodeCollection(newTestBuffer().data, newReadOptions(nil))
+	var keys []string
+
+	require.NoError(t, err)
+
+	// Families are always iterated in the same order, and only the order of the properties of each family differs.
+
+	for key, property := range collection.All() {
+		require.Equal(t, key, property.(*propertyImpl).key())
+
+		keys = append(keys, key)
+	}
+
+	require.Equal(t, []string{"Exif.Image.BitsPerSample", "Exif.Image.Make", "Exif.Photo.FNumber",
+		"Iptc.Application2.DateCreated", "Iptc.Application2.Keywords", "Iptc.Envelope.CharacterSet", "Xmp.dc.subject",
+		"Xmp.dc.title"}, keys)
+
+	keys = nil
+
+	for key := range collection.AllInFileOrder() {
+		keys = append(keys, key)
+	}
+
+	require.Equal(t, []string{"Exif.Image.Make", "Exif.Photo.FNumber", "Exif.Image.BitsPerSample",
+		"Iptc.Envelope.CharacterSet", "Iptc.Application2.Keywords", "Iptc.Application2.DateCreated", "Xmp.dc.title",
+		"Xmp.dc.subject"}, keys)
+
+	// Iteration stops as soon as the loop does, including in the middle of a family.
+
+	for _, stopAfter := range []int{1, 3, 4} {
+		keys = nil
+
+		for key := range collection.All() {
+			keys = append(keys, key)
+
+			if len(keys) == stopAfter {
+				break
+			}
+		}
+
+		require.Len(t, keys, stopAfter)
+	}
+
+	for range newCollection().All() {
+		require.Fail(t, "empty collection has properties")
+	}
+}
+
+func TestKeyOrders(t *testing.T) {
+	var keys []string
+	var properties = newMetadataTestProperties()
+
+	require.Equal(t, []string{"Exif.Image.Make", "Exif.Image.Model", "Exif.Image.Orientation",
+		"Exif.Photo.ExposureTime", "Exif.Photo.FNumber"}, properties.Keys())
+	require.Equal(t, []string{"Exif.Image.Model", "Exif.Image.Make", "Exif.Photo.FNumber", "Exif.Photo.ExposureTime",
+		"Exif.Image.Orientation"}, properties.KeysInFileOrder())
+	require.Equal(t, []string{"Exif.Image.Make", "Exif.Image.Model", "Exif.Image.Orientation",
+		"Exif.Photo.ExposureTime", "Exif.Photo.FNumber"}, properties.KeysInTagOrder())
+
+	// AllInFileOrder iterates in the same order as KeysInFileOrder.
+
+	for key := range properties.AllInFileOrder() {
+		keys = append(keys, key)
+	}
+
+	require.Equal(t, properties.KeysInFileOrder(), keys)
+}
+
+func TestMatchPattern(t *testing.T) {
+	for _, test := range []struct {
+		key     string
+		match   bool
+		pattern string
+	}{
+		{key: "Exif.Photo.FNumber", match: true, pattern: "Exif.Photo.FNumber"},
+		{key: "Exif.Photo.FNumber", match: true, pattern: "Exif.Photo.*"},
+		{key: "Exif.Photo.FNumber", match: true, pattern: "Exif.*"},
+		{key: "Exif.Photo.FNumber", match: true, pattern: "*"},
+		{key: "Exif.Photo.FNumber", match: true, pattern: "Exif.*.F?umber"},
+		{key: "Exif.Photo.FNumber", match: true, pattern: "*Number"},
+		{key: "Exif.Photo.FNumber", match: true, pattern: "Exif.Photo.FNumber**"},
+		{key: "Exif.Photo.FNumber", pattern: "Exif.Photo"},
+		{key: "Exif.Photo.FNumber", pattern: "Exif.Photo.FNumber?"},
+		{key: "Exif.Photo.FNumber", pattern: "Exif.Image.*"},
+		{key: "Exif.Photo.FNumber", pattern: "exif.photo.fnumber"},
+		{key: "Xmp.xmp.Rating", match: true, pattern: "Xmp.*.Rating"},
+		{key: "Xmp.xmp.RatingPercent", pattern: "Xmp.*.Rating"},
+		{key: "Xmp.xmp.Rating", match: true, pattern: "*.*.*"},
+		{key: "", match: true, pattern: "*"},
+		{key: "", pattern: "?"},
+	} {
+		require.Equal(t, test.match, matchPattern(test.pattern, test.key), "pattern '%s', key '%s'", test.pattern,
+			test.key)
+	}
+}
+
+func TestPropertiesFilters(t *testing.T) {
+	var filtered Properties
+	var properties = newMetadataTestProperties()
+	var xmpProperties = newCollection().xmpProperties
+
+	properties.byteOrder = binary.BigEndian
+
+	require.Equal(t, 5, properties.Len())
+	require.Equal(t, []string{"Image", "Photo"}, properties.Groups())
+
+	filtered = properties.InGroup("Image")
+
+	require.Equal(t, 3, filtered.Len())
+	require.Equal(t, []string{"Exif.Image.Make", "Exif.Image.Model", "Exif.Image.Orientation"}, filtered.Keys())
+	require.Equal(t, []string{"Exif.Image.Model", "Exif.Image.Make", "Exif.Image.Orientation"},
+		filtered.KeysInFileOrder())
+	require.Equal(t, []string{"Image"}, filtered.Groups())
+	require.Equal(t, binary.BigEndian, filtered.ByteOrder())
+	require.True(t, filtered.HasKey("Exif.Image.Make"))
+	require.False(t, filtered.HasKey("Exif.Photo.FNumber"))
+	require.Equal(t, properties.Get("Exif.Image.Make"), filtered.Get("Exif.Image.Make"))
+
+	filtered = properties.Match("Exif.*.*a*")
+
+	require.Equal(t, []string{"Exif.Image.Make", "Exif.Image.Orientation"}, filtered.Keys())
+
+	filtered = properties.Match("Exif.*.*Time")
+
+	require.Equal(t, []string{"Exif.Photo.ExposureTime"}, filtered.Keys())
+
+	// Filters can be combined, and may leave nothing.
+
+	require.Equal(t, []string{"Exif.Image.Model"}, properties.InGroup("Image").Match("*.Mod*").Keys())
+	require.Equal(t, 0, properties.InGroup("GPSInfo").Len())
+	require.Empty(t, properties.InGroup("GPSInfo").Groups())
+	require.Equal(t, 0, properties.Match("Xmp.*").Len())
+
+	// Groups are sorted by name even when their keys aren't (e.g., "Xmp.mwg-rs" sorts before "Xmp.mwg.").
+
+	for _, groupName := range []string{"mwg", "mwg-rs", "dc"} {
+		xmpProperties.add(newProperty(FamilyXMP, groupName, "Regions", types.IDXMPText, "", "", false))
+	}
+
+	xmpProperties.finish()
+
+	require.Equal(t, []string{"dc", "mwg", "mwg-rs"}, xmpProperties.Groups())
+	require.Equal(t, []string{"Xmp.mwg.Regions"}, xmpProperties.InGroup("mwg").Keys())
+}
+
+//
+// Private functions
+//
+
+// newMetadataTestProperties creates Exif properties as found in a typical image, where the Exif IFD follows IFD0 and
+// tags aren't necessarily sorted.
+func newMetadataTestProperties() *propertiesImpl {
+	var properties = newCollection().exifProperties
+
+	for _, tag := range []struct {
+		groupName string
+		tagName   string
+		tagNumber uint16
+	}{
+		{groupName: "Image", tagName: "Model", tagNumber: 0x0110},
+		{groupName: "Image", tagName: "Make", tagNumber: 0x010f},
+		{groupName: "Photo", tagName: "FNumber", tagNumber: 0x829d},
+		{groupName: "Photo", tagName: "ExposureTime", tagNumber: 0x829a},
+		{groupName: "Image", tagName: "Orientation", tagNumber: 0x0112},
+	} {
+		var property = newProperty(FamilyExif, tag.groupName, tag.tagName, types.IDAsciiString, "", "", false)
+
+		property.tagNumber = tag.tagNumber
+
+		properties.add(property)
+	}
+
+	properties.finish()
+
+	return properties
+}
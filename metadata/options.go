@@ -0,0 +1,179 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"golang.handcraftedbits.com/ezif/internal"
+)
+
+//
+// Public types
+//
+
+// ReadLimits bounds the amount of metadata that can be read from a single image, protecting against crafted images.  A
+// limit of zero means there is no limit.
+type ReadLimits struct {
+	// MaxProperties is the maximum number of properties.
+	MaxProperties int
+
+	// MaxTotalBytes is the maximum total size, in bytes, of all properties (including their values and names).
+	MaxTotalBytes int
+
+	// MaxValuesPerProperty is the maximum number of values of a single property.  Strings count as a single value, so
+	// their size is only bounded by MaxTotalBytes.
+	MaxValuesPerProperty int
+
+	// MaxXMPPacketSize is the maximum size, in bytes, of the XMP packet.  Exiv2 parses the XMP packet before its size
+	// can be checked, so unlike the other limits this isn't a safety limit: it keeps the properties of an oversized
+	// packet from being returned, but not Exiv2 from spending memory and time parsing it.  Use Isolated() to protect
+	// against crafted XMP packets.
+	MaxXMPPacketSize int
+}
+
+// ReadOption is used to configure how image metadata is read.
+type ReadOption func(options *readOptions)
+
+//
+// Public functions
+//
+
+// Isolated reads images using the worker processes of the given IsolatedReader instead of in this process, so that a
+// crash in Exiv2 can't take down the entire program.
+func Isolated(reader *IsolatedReader) ReadOption {
+	return func(options *readOptions) {
+		options.isolatedReader = reader
+	}
+}
+
+// Lazy defers decoding Exif and XMP property values until Property.Value() is first called, and defers formatting Exif
+// interpreted values until Property.InterpretedValue() is first called.  This greatly reduces the cost of reading
+// images when only a handful of properties are of interest, at the expense of keeping the undecoded metadata in memory
+// for as long as any property is referenced.
+func Lazy() ReadOption {
+	return func(options *readOptions) {
+		options.lazy = true
+	}
+}
+
+// Lenient returns whatever metadata could be read from an image along with the error encountered, instead of just the
+// error.  Note that an image can only be read leniently if it could at least be opened; in that case, both a non-nil
+// Collection and an error are returned by the read functions.  Exceeding a read limit (see Limits()) is always
+// reported as a LimitError, even if Exiv2 also failed to read part of the image.
+func Lenient() ReadOption {
+	return func(options *readOptions) {
+		options.lenient = true
+	}
+}
+
+// Limits sets limits on the amount of metadata that can be read from a single image.  If a limit is exceeded, reading
+// fails with a *LimitError.
+func Limits(limits ReadLimits) ReadOption {
+	return func(options *readOptions) {
+		options.limits = limits
+	}
+}
+
+// LogWarnings forwards the warnings reported by Exiv2 while reading an image to the ezif log (see Collection.Warnings()
+// and Logger()), using a log level matching the severity of each warning.
+func LogWarnings() ReadOption {
+	return func(options *readOptions) {
+		options.logWarnings = true
+	}
+}
+
+// Logger sets the logger used while reading images, which defaults to a logger configured using the EZIF_LOG_FORMAT
+// and EZIF_LOG_LEVEL environment variables.  A nil logger is ignored.
+func Logger(logger *slog.Logger) ReadOption {
+	return func(options *readOptions) {
+		if logger != nil {
+			options.logger = logger
+		}
+	}
+}
+
+// OnlyFamilies limits the properties that are read to those belonging to the given families.
+func OnlyFamilies(families ...Family) ReadOption {
+	return func(options *readOptions) {
+		for _, family := range families {
+			options.patterns = append(options.patterns, string(family)+".*")
+		}
+	}
+}
+
+// OnlyGroups limits the properties that are read to those belonging to the given groups, which are specified as a
+// family and group name separated by a period (e.g., "Exif.Photo").
+func OnlyGroups(groups ...string) ReadOption {
+	return func(options *readOptions) {
+		for _, group := range groups {
+			options.patterns = append(options.patterns, group+".*")
+		}
+	}
+}
+
+// OnlyKeys limits the properties that are read to those whose keys match at least one of the given patterns.  In a
+// pattern, * matches any sequence of characters (including periods) and ? matches any single character, so both
+// "Exif.Photo.*" and "Xmp.xmp.Rating" are valid patterns.
+//
+// OnlyFamilies, OnlyGroups and OnlyKeys can be combined, in which case a property is read if it is selected by any of
+// them.  Filtering takes place before properties are handed over to Go, so properties that aren't selected cost next to
+// nothing.
+func OnlyKeys(patterns ...string) ReadOption {
+	return func(options *readOptions) {
+		options.patterns = append(options.patterns, patterns...)
+	}
+}
+
+// Strict treats warnings reported by Exiv2 (see Collection.Warnings()) as errors, so that reading an image fails with a
+// *WarningError if Exiv2 reports anything with a severity of SeverityWarning or higher.
+func Strict() ReadOption {
+	return func(options *readOptions) {
+		options.strict = true
+	}
+}
+
+// Workers sets the number of files ReadMany reads concurrently, which defaults to the number of CPUs.  Values less than
+// one are ignored.  Workers has no effect on functions that read a single image.
+func Workers(workers int) ReadOption {
+	return func(options *readOptions) {
+		if workers > 0 {
+			options.workers = workers
+		}
+	}
+}
+
+//
+// Private types
+//
+
+type readOptions struct {
+	ctx            context.Context
+	isolatedReader *IsolatedReader
+	lazy           bool
+	lenient        bool
+	limits         ReadLimits
+	logWarnings    bool
+	logger         *slog.Logger
+	patterns       []string
+	strict         bool
+	workers        int
+}
+
+//
+// Private functions
+//
+
+func newReadOptions(options []ReadOption) *readOptions {
+	var result = &readOptions{
+		ctx:     context.Background(),
+		logger:  internal.Log,
+		workers: runtime.NumCPU(),
+	}
+
+	for _, option := range options {
+		option(result)
+	}
+
+	return result
+}
@@ -8,99 +8,262 @@ package metadata // import "golang.handcraftedbits.com/ezif/metadata"
 import "C"
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
 	"unsafe"
-
-	gopointer "github.com/mattn/go-pointer"
-	log "github.com/sirupsen/logrus"
-
-	"golang.handcraftedbits.com/ezif/internal"
 )
 
 //
 // Public functions
 //
 
-func FromFile(filename string) (Collection, error) {
-	return readCollection(func(cExiv2Error *C.struct_exiv2Error, cValueHolder *C.struct_valueHolder,
-		cReadHandler *C.struct_readHandler, rhPointer unsafe.Pointer) {
-		var cFilename = C.CString(filename)
-
-		defer C.free(unsafe.Pointer(cFilename))
-
-		if log.IsLevelEnabled(log.InfoLevel) {
-			internal.Log.WithFields(log.Fields{
-				"filename": filename,
-			}).Info("reading image metadata from file")
-		}
-
-		C.readCollectionFromFile(cFilename, cExiv2Error, cValueHolder, cReadHandler, rhPointer)
-	})
+// FromBytes reads the metadata of an image held in memory.
+func FromBytes(data []byte, options ...ReadOption) (Collection, error) {
+	return readCollection(&imageSource{data: data, kind: imageSourceBytes}, newReadOptions(options))
 }
 
-func FromURL(url string) (Collection, error) {
-	return readCollection(func(cExiv2Error *C.struct_exiv2Error, cValueHolder *C.struct_valueHolder,
-		cReadHandler *C.struct_readHandler, rhPointer unsafe.Pointer) {
-		var cURL = C.CString(url)
+func FromFile(filename string, options ...ReadOption) (Collection, error) {
+	return readCollection(&imageSource{kind: imageSourceFile, location: filename}, newReadOptions(options))
+}
 
-		defer C.free(unsafe.Pointer(cURL))
+func FromURL(url string, options ...ReadOption) (Collection, error) {
+	return readCollection(&imageSource{kind: imageSourceURL, location: url}, newReadOptions(options))
+}
 
-		if log.IsLevelEnabled(log.InfoLevel) {
-			internal.Log.WithFields(log.Fields{
-				"url": url,
-			}).Info("reading image metadata from URL")
-		}
+//
+// Private types
+//
 
-		C.readCollectionFromURL(cURL, cExiv2Error, cValueHolder, cReadHandler, rhPointer)
-	})
+// imageSource describes where an image is read from.
+type imageSource struct {
+	data     []byte
+	kind     uint8
+	location string
 }
 
+type readCollectionInvoker func(cOptions *C.struct_readOptions, cExiv2Error *C.struct_exiv2Error,
+	cBuffer *C.struct_exiv2Buffer)
+
 //
-// Private types
+// Private constants
 //
 
-type readCollectionInvoker func(cExiv2Error *C.struct_exiv2Error, cValueHolder *C.struct_valueHolder,
-	cReadHandler *C.struct_readHandler, rhPointer unsafe.Pointer)
+const (
+	imageSourceBytes = 1
+	imageSourceFile  = 2
+	imageSourceURL   = 3
+)
 
 //
 // Private functions
 //
 
-func cReadCollection(invoker readCollectionInvoker, handler *readHandler) error {
+func cReadCollection(options *readOptions, invoker readCollectionInvoker) ([]byte, error) {
+	var buffer []byte
+	var cBuffer = C.struct_exiv2Buffer{}
 	var cExiv2Error = C.struct_exiv2Error{
 		code: C.int(-999),
 	}
-	var cReadHandler = C.struct_readHandler{
-		poec: C.propertyOnEndCallback(C.onPropertyEnd),
-		posc: C.propertyOnStartCallback(C.onPropertyStart),
-		vc:   C.valueCallback(C.onValue),
+	var cOptions = C.struct_readOptions{}
+
+	if options.lazy {
+		cOptions.lazy = C.int(1)
+	}
+
+	if options.lenient {
+		cOptions.lenient = C.int(1)
+	}
+
+	cOptions.maxProperties = C.size_t(nonNegative(options.limits.MaxProperties))
+	cOptions.maxTotalBytes = C.size_t(nonNegative(options.limits.MaxTotalBytes))
+	cOptions.maxValuesPerProperty = C.size_t(nonNegative(options.limits.MaxValuesPerProperty))
+	cOptions.maxXMPPacketSize = C.size_t(nonNegative(options.limits.MaxXMPPacketSize))
+
+	// The key patterns have to be passed as an array of C strings, which means the array must live in C memory too.
+
+	if len(options.patterns) > 0 {
+		var cPatterns = (*[1 << 20]*C.char)(C.malloc(C.size_t(len(options.patterns)) *
+			C.size_t(unsafe.Sizeof((*C.char)(nil)))))[:len(options.patterns):len(options.patterns)]
+
+		defer C.free(unsafe.Pointer(&cPatterns[0]))
+
+		for i, pattern := range options.patterns {
+			cPatterns[i] = C.CString(pattern)
+
+			defer C.free(unsafe.Pointer(cPatterns[i]))
+		}
+
+		cOptions.patterns = &cPatterns[0]
+		cOptions.numPatterns = C.size_t(len(cPatterns))
 	}
-	var cValueHolder = C.struct_valueHolder{}
-	var rhPointer = gopointer.Save(handler)
 
-	defer gopointer.Unref(rhPointer)
+	invoker(&cOptions, &cExiv2Error, &cBuffer)
+
+	// Copying the buffer into Go memory is a single (cheap) operation, and saves us from having to worry about the
+	// lifetime of C memory while decoding.  When reading leniently, there may be a buffer even if there's an error.
 
-	invoker(&cExiv2Error, &cValueHolder, &cReadHandler, rhPointer)
+	if cBuffer.data != nil {
+		defer C.free(unsafe.Pointer(cBuffer.data))
+
+		buffer = C.GoBytes(unsafe.Pointer(cBuffer.data), C.int(cBuffer.length))
+	}
 
 	if cExiv2Error.code != C.int(-999) {
 		defer C.free(unsafe.Pointer(cExiv2Error.message))
 
-		// Not using %s because it creates a spurious warning about the argument not being a string.
+		if cExiv2Error.limit != 0 {
+			return buffer, newLimitError(int(cExiv2Error.limit), &options.limits)
+		}
+
+		return buffer, newError(int(cExiv2Error.code), int(cExiv2Error.kind), C.GoString(cExiv2Error.message))
+	}
+
+	return buffer, nil
+}
+
+func logWarnings(ctx context.Context, logger *slog.Logger, source *imageSource, warnings []Warning) {
+	for _, warning := range warnings {
+		var attrs = []slog.Attr{slog.String("message", warning.Message)}
+		var level slog.Level
+		var message string
+
+		if source.kind != imageSourceBytes {
+			attrs = append(attrs, slog.String("source", source.location))
+		}
+
+		switch warning.Severity {
+		case SeverityDebug:
+			level, message = slog.LevelDebug, "Exiv2 reported a message"
+
+		case SeverityInfo:
+			level, message = slog.LevelInfo, "Exiv2 reported a message"
 
-		return fmt.Errorf(C.GoString(cExiv2Error.message)+" (Exiv2 error code %d)", int(cExiv2Error.code))
+		case SeverityWarning:
+			level, message = slog.LevelWarn, "Exiv2 reported a warning"
+
+		default:
+			level, message = slog.LevelError, "Exiv2 reported an error"
+		}
+
+		logger.LogAttrs(ctx, level, message, attrs...)
+	}
+}
+
+func nonNegative(value int) int {
+	if value < 0 {
+		return 0
+	}
+
+	return value
+}
+
+// readBuffer reads the metadata of an image in this process, returning the serialized metadata buffer.
+func readBuffer(source *imageSource, options *readOptions) ([]byte, error) {
+	var buffer []byte
+	var err error
+
+	if err = initialize(options.ctx, options.logger); err != nil {
+		return nil, err
+	}
+
+	buffer, err = cReadCollection(options, func(cOptions *C.struct_readOptions, cExiv2Error *C.struct_exiv2Error,
+		cBuffer *C.struct_exiv2Buffer) {
+		switch source.kind {
+		case imageSourceBytes:
+			var cData unsafe.Pointer
+
+			if options.logger.Enabled(options.ctx, slog.LevelInfo) {
+				options.logger.InfoContext(options.ctx, "reading image metadata from memory",
+					"length", len(source.data),
+				)
+			}
+
+			// Exiv2 can't be handed Go memory since it may hold on to it (e.g., in an exception), so make a copy.
+
+			if len(source.data) > 0 {
+				cData = C.CBytes(source.data)
+
+				defer C.free(cData)
+			}
+
+			C.readCollectionFromBytes((*C.uchar)(cData), C.size_t(len(source.data)), cOptions, cExiv2Error, cBuffer)
+
+		case imageSourceFile:
+			var cFilename = C.CString(source.location)
+
+			defer C.free(unsafe.Pointer(cFilename))
+
+			if options.logger.Enabled(options.ctx, slog.LevelInfo) {
+				options.logger.InfoContext(options.ctx, "reading image metadata from file",
+					"filename", source.location,
+				)
+			}
+
+			C.readCollectionFromFile(cFilename, cOptions, cExiv2Error, cBuffer)
+
+		case imageSourceURL:
+			var cURL = C.CString(source.location)
+
+			defer C.free(unsafe.Pointer(cURL))
+
+			if options.logger.Enabled(options.ctx, slog.LevelInfo) {
+				options.logger.InfoContext(options.ctx, "reading image metadata from URL",
+					"url", source.location,
+				)
+			}
+
+			C.readCollectionFromURL(cURL, cOptions, cExiv2Error, cBuffer)
+		}
+	})
+
+	// Exiv2 doesn't distinguish between a file that doesn't exist and one that can't be opened for some other reason.
+
+	if exiv2Err, ok := err.(*Error); ok && exiv2Err.kind == ErrIO && source.kind == imageSourceFile {
+		if _, statErr := os.Stat(source.location); os.IsNotExist(statErr) {
+			exiv2Err.kind = ErrNotFound
+		}
 	}
 
-	return nil
+	return buffer, err
 }
 
-func readCollection(invoker readCollectionInvoker) (Collection, error) {
-	var handler = newReadHandler()
+func readCollection(source *imageSource, options *readOptions) (Collection, error) {
+	var buffer []byte
+	var collection *collectionImpl
+	var decodeErr, err error
 
-	if err := cReadCollection(invoker, handler); err != nil {
+	if options.isolatedReader != nil {
+		buffer, err = options.isolatedReader.readBuffer(source, options)
+	} else {
+		buffer, err = readBuffer(source, options)
+	}
+
+	// An error along with a buffer means the image was read leniently and only part of its metadata could be read.
+
+	if buffer == nil {
 		return nil, err
 	}
 
-	handler.finish()
+	// When reading leniently, the metadata read before the error may itself be unusable, in which case the caller
+	// needs to know about both errors.
+
+	if collection, decodeErr = decodeCollection(buffer, options); decodeErr != nil {
+		return nil, errors.Join(err, decodeErr)
+	}
+
+	if options.logWarnings {
+		logWarnings(options.ctx, options.logger, source, collection.warnings)
+	}
+
+	if options.strict {
+		for _, warning := range collection.warnings {
+			if warning.Severity >= SeverityWarning {
+				return nil, &WarningError{Warning: warning}
+			}
+		}
+	}
 
-	return handler.metadata, nil
+	return collection, err
 }
@@ -0,0 +1,449 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.handcraftedbits.com/ezif/internal"
+	"golang.handcraftedbits.com/ezif/internal/testimage"
+)
+
+//
+// Public functions
+//
+
+// BenchmarkReadLargeExif reads an image with large Exif array values (e.g., thousands of strip offsets), which used to
+// require a callback into Go for every single value.
+func BenchmarkReadLargeExif(b *testing.B) {
+	var byteCounts = make([]uint32, benchmarkArrayLength)
+	var offsets = make([]uint32, benchmarkArrayLength)
+
+	for i := range offsets {
+		byteCounts[i] = 8192
+		offsets[i] = uint32(i * 8192)
+	}
+
+	benchmarkRead(b, &testimage.Image{
+		Exif: []testimage.Entry{
+			testimage.ASCII(0x010F, "Camera"),
+			testimage.Longs(0x0111, offsets...),
+			testimage.Longs(0x0117, byteCounts...),
+		},
+		ExifIFD: []testimage.Entry{
+			testimage.Rationals(0x829D, [2]uint32{28, 10}),
+		},
+	})
+}
+
+// BenchmarkReadXMPHeavy reads an image with a large XMP packet, made of many array items and simple properties.
+func BenchmarkReadXMPHeavy(b *testing.B) {
+	var properties strings.Builder
+
+	properties.WriteString("<dc:subject><rdf:Bag>")
+
+	for i := 0; i < benchmarkArrayLength/4; i++ {
+		fmt.Fprintf(&properties, "<rdf:li>subject%d</rdf:li>", i)
+	}
+
+	properties.WriteString("</rdf:Bag></dc:subject>")
+	properties.WriteString("<dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">Title</rdf:li>" +
+		"<rdf:li xml:lang=\"fr\">Titre</rdf:li></rdf:Alt></dc:title>")
+
+	for i := 0; i < benchmarkArrayLength/16; i++ {
+		fmt.Fprintf(&properties, "<xmp:Label%d>Label %d</xmp:Label%d>", i, i, i)
+	}
+
+	benchmarkRead(b, &testimage.Image{
+		XMP: testimage.XMPPacket(properties.String()),
+	})
+}
+
+// TestReadConcurrently reads the same images from many goroutines at once, which is mostly useful when run with the
+// race detector (i.e., "go test -race" or "make test EZIF_TEST_RACE=1").
+func TestLogWarnings(t *testing.T) {
+	var output bytes.Buffer
+	var logger = slog.New(slog.NewTextHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	var lines []string
+	var warnings = []Warning{
+		{Message: "debug", Severity: SeverityDebug},
+		{Message: "info", Severity: SeverityInfo},
+		{Message: "warning", Severity: SeverityWarning},
+		{Message: "error", Severity: SeverityError},
+	}
+
+	logWarnings(context.Background(), logger, &imageSource{kind: imageSourceFile, location: "image.jpg"}, warnings)
+
+	lines = strings.Split(strings.TrimSpace(output.String()), "\n")
+
+	require.Len(t, lines, 4)
+
+	for i, level := range []string{"DEBUG", "INFO", "WARN", "ERROR"} {
+		require.Contains(t, lines[i], "level="+level)
+		require.Contains(t, lines[i], "message="+warnings[i].Message)
+		require.Contains(t, lines[i], "source=image.jpg")
+	}
+
+	// Images read from memory have no source to report.
+
+	output.Reset()
+
+	logWarnings(context.Background(), logger, &imageSource{data: []byte("image"), kind: imageSourceBytes},
+		warnings[2:3])
+
+	require.Contains(t, output.String(), "level=WARN")
+	require.NotContains(t, output.String(), "source=")
+}
+
+func TestReadConcurrently(t *testing.T) {
+	var directory = t.TempDir()
+	var errs = make(chan error, testReadGoroutines)
+	var filenames = make([]string, testReadImages)
+	var images = make([][]byte, testReadImages)
+	var waitGroup sync.WaitGroup
+
+	for i := range images {
+		images[i] = newTestImage(i).JPEG()
+		filenames[i] = filepath.Join(directory, fmt.Sprintf("image%d.jpg", i))
+
+		require.NoError(t, os.WriteFile(filenames[i], images[i], 0600))
+	}
+
+	for i := 0; i < testReadGoroutines; i++ {
+		waitGroup.Add(1)
+
+		go func(goroutine int) {
+			defer waitGroup.Done()
+
+			for j := range images {
+				var collection Collection
+				var err error
+
+				// Mix the ways images are read, so that deferred decoding also happens concurrently.
+
+				if goroutine%2 == 0 {
+					collection, err = FromFile(filenames[j])
+				} else {
+					collection, err = FromBytes(images[j], Lazy())
+				}
+
+				if err == nil {
+					err = checkTestImage(collection, j)
+				}
+
+				if err != nil {
+					errs <- fmt.Errorf("goroutine %d, image %d: %w", goroutine, j, err)
+
+					return
+				}
+			}
+		}(i)
+	}
+
+	waitGroup.Wait()
+
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	var err error
+
+	_, err = FromFile(filepath.Join(t.TempDir(), "missing.jpg"))
+
+	require.True(t, errors.Is(err, ErrNotFound), "unexpected error: %v", err)
+
+	_, err = FromBytes([]byte("not an image"))
+
+	require.True(t, errors.Is(err, ErrUnsupportedFormat), "unexpected error: %v", err)
+}
+
+// TestReadLenient uses an isolated worker that responds as Exiv2 would when failing to read a corrupt image.
+func TestReadLenient(t *testing.T) {
+	var collection Collection
+	var err error
+	var reader = newTestIsolatedReader(t, testWorkerCorrupt, IsolatedReaderConfig{Workers: 1})
+
+	collection, err = FromBytes([]byte("image"), Isolated(reader))
+
+	require.Nil(t, collection)
+	require.True(t, errors.Is(err, ErrCorrupt), "unexpected error: %v", err)
+
+	// Whatever was read before the failure is returned along with the error.
+
+	collection, err = FromBytes([]byte("image"), Isolated(reader), Lenient())
+
+	require.True(t, errors.Is(err, ErrCorrupt), "unexpected error: %v", err)
+	require.NotNil(t, collection)
+	require.Equal(t, []string{"Canon"}, collection.Exif().Get("Exif.Image.Make").Value())
+	require.Len(t, collection.Warnings(), 2)
+}
+
+func TestReadLimits(t *testing.T) {
+	var data = newTestImage(0).JPEG()
+	var tests = []struct {
+		limit  string
+		limits ReadLimits
+		name   string
+	}{
+		{
+			limits: ReadLimits{MaxProperties: 100, MaxTotalBytes: 1 << 20, MaxValuesPerProperty: 100,
+				MaxXMPPacketSize: 1 << 20},
+			name: "NotExceeded",
+		},
+		{
+			limit:  "MaxProperties",
+			limits: ReadLimits{MaxProperties: 1},
+			name:   "Properties",
+		},
+		{
+			limit:  "MaxTotalBytes",
+			limits: ReadLimits{MaxTotalBytes: 16},
+			name:   "TotalBytes",
+		},
+		{
+			limit:  "MaxValuesPerProperty",
+			limits: ReadLimits{MaxValuesPerProperty: 1},
+			name:   "ValuesPerProperty",
+		},
+		{
+			limit:  "MaxXMPPacketSize",
+			limits: ReadLimits{MaxXMPPacketSize: 16},
+			name:   "XMPPacketSize",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var collection, err = FromBytes(data, Limits(test.limits))
+			var limitErr *LimitError
+
+			if test.limit == "" {
+				require.NoError(t, err)
+				require.NoError(t, checkTestImage(collection, 0))
+
+				return
+			}
+
+			require.Nil(t, collection)
+			require.True(t, errors.Is(err, ErrLimitExceeded), "unexpected error: %v", err)
+			require.True(t, errors.As(err, &limitErr))
+			require.Equal(t, test.limit, limitErr.Limit)
+		})
+	}
+}
+
+func TestReadLogger(t *testing.T) {
+	var err error
+	var readOutput, workerOutput bytes.Buffer
+	var reader = newTestIsolatedReader(t, testWorkerWarning, IsolatedReaderConfig{
+		Logger:  slog.New(slog.NewTextHandler(&workerOutput, nil)),
+		Workers: 1,
+	})
+
+	require.Equal(t, internal.Log, newReadOptions(nil).logger)
+	require.Equal(t, internal.Log, newReadOptions([]ReadOption{Logger(nil)}).logger)
+
+	// Workers are managed using the logger of the isolated reader, while warnings are logged using the logger of the
+	// read.
+
+	_, err = FromBytes([]byte("image"), Isolated(reader), LogWarnings(),
+		Logger(slog.New(slog.NewTextHandler(&readOutput, nil))))
+
+	require.NoError(t, err)
+	require.Contains(t, readOutput.String(), "message=warning")
+	require.NotContains(t, readOutput.String(), "started isolated worker")
+	require.Contains(t, workerOutput.String(), "started isolated worker")
+	require.NotContains(t, workerOutput.String(), "message=warning")
+}
+
+func TestReadSelected(t *testing.T) {
+	var data = newTestImage(0).JPEG()
+	var all, err = FromBytes(data)
+	var tests = []struct {
+		name     string
+		options  []ReadOption
+		patterns []string
+		want     []string
+	}{
+		{
+			name:     "Families",
+			options:  []ReadOption{OnlyFamilies(FamilyExif)},
+			patterns: []string{"Exif.*"},
+			want:     []string{"Exif.Image.Make", "Exif.Photo.FNumber"},
+		},
+		{
+			name:     "Groups",
+			options:  []ReadOption{OnlyGroups("Exif.Photo")},
+			patterns: []string{"Exif.Photo.*"},
+			want:     []string{"Exif.Photo.FNumber"},
+		},
+		{
+			name:     "Keys",
+			options:  []ReadOption{OnlyKeys("Iptc.Application2.Keywords", "Xmp.*.subject")},
+			patterns: []string{"Iptc.Application2.Keywords", "Xmp.*.subject"},
+			want:     []string{"Iptc.Application2.Keywords", "Xmp.dc.subject"},
+		},
+		{
+			name:     "Combined",
+			options:  []ReadOption{OnlyGroups("Exif.Image"), OnlyKeys("Xmp.dc.subject")},
+			patterns: []string{"Exif.Image.*", "Xmp.dc.subject"},
+			want:     []string{"Exif.Image.Make", "Xmp.dc.subject"},
+		},
+	}
+
+	require.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var collection, err = FromBytes(data, test.options...)
+			var keys []string
+
+			require.NoError(t, err)
+
+			for key := range collection.All() {
+				require.True(t, slices.ContainsFunc(test.patterns, func(pattern string) bool {
+					return matchPattern(pattern, key)
+				}), "property '%s' wasn't selected", key)
+
+				keys = append(keys, key)
+			}
+
+			// Selected properties have the same values as when everything is read.
+
+			for _, key := range test.want {
+				require.Contains(t, keys, key)
+				require.Equal(t, getProperty(all, key).Value(), getProperty(collection, key).Value())
+			}
+		})
+	}
+}
+
+// TestReadStrict uses an isolated worker that responds with an info message and a warning along with the metadata.
+func TestReadStrict(t *testing.T) {
+	var collection Collection
+	var err error
+	var reader = newTestIsolatedReader(t, testWorkerWarning, IsolatedReaderConfig{Workers: 1})
+	var warningErr *WarningError
+
+	collection, err = FromBytes([]byte("image"), Isolated(reader))
+
+	require.NoError(t, err)
+	require.Equal(t, []Warning{
+		{Message: "info", Severity: SeverityInfo},
+		{Message: "warning", Severity: SeverityWarning},
+	}, collection.Warnings())
+
+	// Only warnings and errors fail a strict read, not informational messages.
+
+	collection, err = FromBytes([]byte("image"), Isolated(reader), Strict())
+
+	require.Nil(t, collection)
+	require.True(t, errors.Is(err, ErrWarning), "unexpected error: %v", err)
+	require.True(t, errors.As(err, &warningErr))
+	require.Equal(t, Warning{Message: "warning", Severity: SeverityWarning}, warningErr.Warning)
+}
+
+//
+// Private constants
+//
+
+const (
+	benchmarkArrayLength = 4000
+	testReadGoroutines   = 32
+	testReadImages       = 16
+)
+
+//
+// Private functions
+//
+
+func benchmarkRead(b *testing.B, image *testimage.Image) {
+	var data = image.JPEG()
+
+	for _, benchmark := range []struct {
+		name    string
+		options []ReadOption
+	}{
+		{
+			name: "Eager",
+		},
+		{
+			name:    "Lazy",
+			options: []ReadOption{Lazy()},
+		},
+	} {
+		b.Run(benchmark.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+
+			for i := 0; i < b.N; i++ {
+				if _, err := FromBytes(data, benchmark.options...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func checkTestImage(collection Collection, index int) error {
+	var expectedKeywords = []string{"keyword", strconv.Itoa(index)}
+	var expectedMake = fmt.Sprintf("Camera %d", index)
+	var expectedSubjects = []string{"subject", strconv.Itoa(index)}
+
+	if value, _, err := Get[string](collection.Exif(), "Exif.Image.Make"); err != nil || value != expectedMake {
+		return fmt.Errorf("expected Exif.Image.Make '%s', found '%s' (%v)", expectedMake, value, err)
+	}
+
+	if value, _, err := Get[[]string](collection.IPTC(), "Iptc.Application2.Keywords"); err != nil ||
+		fmt.Sprint(value) != fmt.Sprint(expectedKeywords) {
+		return fmt.Errorf("expected Iptc.Application2.Keywords %v, found %v (%v)", expectedKeywords, value, err)
+	}
+
+	if value, _, err := Get[[]string](collection.XMP(), "Xmp.dc.subject"); err != nil ||
+		fmt.Sprint(value) != fmt.Sprint(expectedSubjects) {
+		return fmt.Errorf("expected Xmp.dc.subject %v, found %v (%v)", expectedSubjects, value, err)
+	}
+
+	return nil
+}
+
+// newTestImage creates a small image whose metadata depends on the given index, see checkTestImage().
+func newTestImage(index int) *testimage.Image {
+	var image = &testimage.Image{
+		ByteOrder: binary.LittleEndian,
+		Exif: []testimage.Entry{
+			testimage.ASCII(0x010F, fmt.Sprintf("Camera %d", index)),
+		},
+		ExifIFD: []testimage.Entry{
+			testimage.Rationals(0x829D, [2]uint32{28, 10}),
+		},
+		IPTC: []testimage.Dataset{
+			{DataSet: 25, Record: 2, Value: []byte("keyword")},
+			{DataSet: 25, Record: 2, Value: []byte(strconv.Itoa(index))},
+		},
+		XMP: testimage.XMPPacket(fmt.Sprintf("<dc:subject><rdf:Bag><rdf:li>subject</rdf:li><rdf:li>%d</rdf:li>"+
+			"</rdf:Bag></dc:subject>", index)),
+	}
+
+	if index%2 != 0 {
+		image.ByteOrder = binary.BigEndian
+	}
+
+	return image
+}
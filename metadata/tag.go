@@ -0,0 +1,80 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+/*
+#include <stdlib.h>
+
+#include "exiv2.h"
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+//
+// Private types
+//
+
+// tagInfo describes a metadata property as defined by Exiv2.
+type tagInfo struct {
+	label      string
+	repeatable bool
+	tagNumber  uint16
+	typeId     types.ID
+}
+
+//
+// Private functions
+//
+
+// lookupTag asks Exiv2 for the definition of the metadata property with the given key.  Properties belonging to
+// custom XMP namespaces use the definitions given to RegisterNamespace(), if any.
+func lookupTag(key string) (*tagInfo, error) {
+	var cExiv2Error = C.struct_exiv2Error{
+		code: C.int(-999),
+	}
+	var cKey *C.char
+	var cTagInfo = C.struct_exiv2TagInfo{}
+
+	xmpDefinitionsMutex.RLock()
+
+	var definition, ok = xmpDefinitions[key]
+
+	xmpDefinitionsMutex.RUnlock()
+
+	if ok {
+		return &tagInfo{
+			label:  definition.Label,
+			typeId: definition.TypeID,
+		}, nil
+	}
+
+	if err := Initialize(); err != nil {
+		return nil, err
+	}
+
+	cKey = C.CString(key)
+
+	defer C.free(unsafe.Pointer(cKey))
+
+	C.lookupTag(cKey, &cTagInfo, &cExiv2Error)
+
+	if cTagInfo.label != nil {
+		defer C.free(unsafe.Pointer(cTagInfo.label))
+	}
+
+	if cExiv2Error.code != C.int(-999) {
+		defer C.free(unsafe.Pointer(cExiv2Error.message))
+
+		return nil, newError(int(cExiv2Error.code), int(cExiv2Error.kind), C.GoString(cExiv2Error.message))
+	}
+
+	return &tagInfo{
+		label:      C.GoString(cTagInfo.label),
+		repeatable: cTagInfo.repeatable != 0,
+		tagNumber:  uint16(cTagInfo.tag),
+		typeId:     types.ID(cTagInfo.typeId),
+	}, nil
+}
@@ -0,0 +1,520 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+//
+// Public functions
+//
+
+// Unmarshal fills in the fields of the struct pointed to by v with the values of metadata properties, as specified by
+// "ezif" field tags containing the key of a property followed by optional comma-separated options:
+//
+//	FNumber  float64   `ezif:"Exif.Photo.FNumber"`
+//	Flash    string    `ezif:"Exif.Photo.Flash,interpreted"`
+//	Keywords []string  `ezif:"Iptc.Application2.Keywords"`
+//	Title    string    `ezif:"Xmp.dc.title,lang=en"`
+//	Taken    time.Time `ezif:"Exif.Photo.DateTimeOriginal"`
+//
+// The "interpreted" option uses the interpreted value of the property instead of its value, and the "lang" option
+// selects a language of an XMP language alternative (x-default is used otherwise, unless the field is a
+// map[string]string).  Fields of embedded structs are filled in as well.
+//
+// Values are converted to the type of their field where it makes sense: rationals and other numbers can be stored in
+// any numeric field as long as they fit, strings are parsed as numbers or booleans if needed, IPTC dates and times and
+// Exif and XMP date strings can be stored in time.Time fields, and anything can be stored in a string field.  Undefined
+// values that are just text (e.g., Exif.Photo.ExifVersion) are stored in string fields as a single string, and other
+// bytes as numbers.  If a property has multiple values and its field isn't a slice, only the first value is used.  Fields whose properties
+// are missing are left untouched, as are pointer fields, which are allocated when there's a value to store.
+//
+// If some values can't be converted, the remaining fields are still filled in and an *UnmarshalError describing each
+// failed field is returned.
+func Unmarshal(collection Collection, v interface{}) error {
+	var fieldErrors []*FieldError
+	var value = reflect.ValueOf(v)
+
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot unmarshal metadata into %T, a non-nil struct pointer is required", v)
+	}
+
+	if err := unmarshalStruct(collection, value.Elem(), &fieldErrors); err != nil {
+		return err
+	}
+
+	if len(fieldErrors) > 0 {
+		return &UnmarshalError{
+			Fields: fieldErrors,
+		}
+	}
+
+	return nil
+}
+
+//
+// Private types
+//
+
+type unmarshalTag struct {
+	interpreted bool
+	key         string
+	language    string
+}
+
+//
+// Private constants
+//
+
+const unmarshalTagName = "ezif"
+
+//
+// Private variables
+//
+
+// The formats used to parse date strings (Exif dates first, then the ISO 8601 subsets used by XMP).
+var dateLayouts = []string{
+	"2006:01:02 15:04:05",
+	"2006:01:02",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+var errValueOutOfRange = errors.New("value out of range")
+
+var typeTime = reflect.TypeOf(time.Time{})
+
+//
+// Private functions
+//
+
+func convertScalar(source interface{}, target reflect.Value) error {
+	var sourceValue = reflect.ValueOf(source)
+
+	if sourceValue.IsValid() && sourceValue.Type().AssignableTo(target.Type()) {
+		target.Set(sourceValue)
+
+		return nil
+	}
+
+	if target.Type() == typeTime {
+		var result, err = convertToTime(source)
+
+		if err == nil {
+			target.Set(reflect.ValueOf(result))
+		}
+
+		return err
+	}
+
+	switch target.Kind() {
+	case reflect.Bool:
+		switch source := source.(type) {
+		case string:
+			var result, err = strconv.ParseBool(source)
+
+			if err == nil {
+				target.SetBool(result)
+			}
+
+			return err
+
+		default:
+			if number, ok := toFloat(source); ok {
+				target.SetBool(number != 0)
+
+				return nil
+			}
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if number, ok := toFloat(source); ok {
+			if target.OverflowFloat(number) {
+				return errValueOutOfRange
+			}
+
+			target.SetFloat(number)
+
+			return nil
+		}
+
+		if str, ok := source.(string); ok {
+			var number, err = strconv.ParseFloat(strings.TrimSpace(str), target.Type().Bits())
+
+			if err == nil {
+				target.SetFloat(number)
+			}
+
+			return err
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var number, err = toInt(source)
+
+		if err != nil {
+			return err
+		}
+
+		if target.OverflowInt(number) {
+			return errValueOutOfRange
+		}
+
+		target.SetInt(number)
+
+		return nil
+
+	case reflect.String:
+		switch source := source.(type) {
+		case *big.Rat:
+			target.SetString(source.RatString())
+
+		case fmt.Stringer:
+			target.SetString(source.String())
+
+		default:
+			target.SetString(fmt.Sprint(source))
+		}
+
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var number, err = toInt(source)
+
+		if err != nil {
+			return err
+		}
+
+		if number < 0 || target.OverflowUint(uint64(number)) {
+			return errValueOutOfRange
+		}
+
+		target.SetUint(uint64(number))
+
+		return nil
+	}
+
+	return fmt.Errorf("cannot convert %T to %s", source, target.Type())
+}
+
+func convertToTime(source interface{}) (time.Time, error) {
+	switch source := source.(type) {
+	case types.IPTCDate:
+		return time.Date(source.Year(), source.Month(), source.Day(), 0, 0, 0, 0, time.UTC), nil
+
+	case types.IPTCTime:
+		return time.Date(0, time.January, 1, source.Hour(), source.Minute(), source.Second(), 0, source.Timezone()), nil
+
+	case string:
+		var str = strings.TrimSpace(source)
+
+		for _, layout := range dateLayouts {
+			if result, err := time.Parse(layout, str); err == nil {
+				return result, nil
+			}
+		}
+
+		return time.Time{}, fmt.Errorf("cannot parse '%s' as a date", source)
+	}
+
+	return time.Time{}, fmt.Errorf("cannot convert %T to %s", source, typeTime)
+}
+
+func getProperty(collection Collection, key string) Property {
+	var properties Properties
+
+	switch {
+	case strings.HasPrefix(key, string(FamilyExif)+"."):
+		properties = collection.Exif()
+
+	case strings.HasPrefix(key, string(FamilyIPTC)+"."):
+		properties = collection.IPTC()
+
+	case strings.HasPrefix(key, string(FamilyXMP)+"."):
+		properties = collection.XMP()
+
+	default:
+		return nil
+	}
+
+	if !properties.HasKey(key) {
+		return nil
+	}
+
+	return properties.Get(key)
+}
+
+// getUnmarshalValues returns the values of a property as a slice, or an invalid value if there are no values to store.
+// getUndefinedText returns the text held by an undefined value, if it only consists of printable ASCII characters
+// (ignoring trailing NUL characters).
+func getUndefinedText(typeID types.ID, value []byte) (string, bool) {
+	var text = strings.TrimRight(string(value), "\x00")
+
+	if typeID != types.IDUndefined || text == "" {
+		return "", false
+	}
+
+	for _, r := range text {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			return "", false
+		}
+	}
+
+	return text, true
+}
+
+func getUnmarshalValues(property Property, tag *unmarshalTag, target reflect.Value) reflect.Value {
+	var language = tag.language
+
+	if tag.interpreted {
+		return reflect.ValueOf([]string{property.InterpretedValue()})
+	}
+
+	switch value := property.Value().(type) {
+	case nil:
+		return reflect.Value{}
+
+	// Undefined values are often just text (e.g., Exif.Photo.ExifVersion), in which case they're converted to strings
+	// as a whole rather than byte by byte.
+
+	case []byte:
+		var elemType = target.Type()
+
+		for elemType.Kind() == reflect.Ptr || elemType.Kind() == reflect.Slice {
+			elemType = elemType.Elem()
+		}
+
+		if text, ok := getUndefinedText(property.TypeID(), value); ok && elemType.Kind() == reflect.String {
+			return reflect.ValueOf([]string{text})
+		}
+
+		return reflect.ValueOf(value)
+
+	case []map[string]string:
+		if len(value) == 0 {
+			return reflect.Value{}
+		}
+
+		if language == "" {
+			if target.Type() == reflect.TypeOf(value[0]) {
+				return reflect.ValueOf(value[:1])
+			}
+
+			language = xmpLanguageDefault
+		}
+
+		if str, ok := value[0][language]; ok {
+			return reflect.ValueOf([]string{str})
+		}
+
+		return reflect.Value{}
+
+	default:
+		return reflect.ValueOf(value)
+	}
+}
+
+func parseUnmarshalTag(tagValue string) (*unmarshalTag, error) {
+	var parts = strings.Split(tagValue, ",")
+	var result = &unmarshalTag{
+		key: strings.TrimSpace(parts[0]),
+	}
+
+	if strings.Count(result.key, ".") < 2 {
+		return nil, fmt.Errorf("invalid metadata property key '%s'", result.key)
+	}
+
+	for _, option := range parts[1:] {
+		option = strings.TrimSpace(option)
+
+		switch {
+		case option == "interpreted":
+			result.interpreted = true
+
+		case strings.HasPrefix(option, "lang="):
+			result.language = strings.TrimPrefix(option, "lang=")
+
+		default:
+			return nil, fmt.Errorf("invalid option '%s' for metadata property '%s'", option, result.key)
+		}
+	}
+
+	return result, nil
+}
+
+func storeValues(values reflect.Value, target reflect.Value) error {
+	var targetType = target.Type()
+
+	// Fill in a copy of the target so that it's left untouched if the conversion fails.
+
+	var result = reflect.New(targetType).Elem()
+
+	switch {
+	// Pointer fields are allocated, unless values are pointers themselves (e.g., *big.Rat).
+
+	case targetType.Kind() == reflect.Ptr && !values.Type().Elem().AssignableTo(targetType):
+		var elem = reflect.New(targetType.Elem())
+
+		if err := storeValues(values, elem.Elem()); err != nil {
+			return err
+		}
+
+		result.Set(elem)
+
+	case targetType.Kind() == reflect.Slice && values.Type().AssignableTo(targetType):
+		result.Set(reflect.AppendSlice(reflect.MakeSlice(targetType, 0, values.Len()), values))
+
+	case targetType.Kind() == reflect.Slice:
+		result.Set(reflect.MakeSlice(targetType, values.Len(), values.Len()))
+
+		for i := 0; i < values.Len(); i++ {
+			if err := convertScalar(values.Index(i).Interface(), result.Index(i)); err != nil {
+				return fmt.Errorf("value %d: %v", i, err)
+			}
+		}
+
+	default:
+		if err := convertScalar(values.Index(0).Interface(), result); err != nil {
+			return err
+		}
+	}
+
+	target.Set(result)
+
+	return nil
+}
+
+func toFloat(source interface{}) (float64, bool) {
+	switch source := source.(type) {
+	case *big.Rat:
+		var result, _ = source.Float64()
+
+		return result, true
+
+	case float32:
+		return float64(source), true
+
+	case float64:
+		return source, true
+
+	case int8:
+		return float64(source), true
+
+	case int16:
+		return float64(source), true
+
+	case int32:
+		return float64(source), true
+
+	case uint8:
+		return float64(source), true
+
+	case uint16:
+		return float64(source), true
+
+	case uint32:
+		return float64(source), true
+	}
+
+	return 0, false
+}
+
+func toInt(source interface{}) (int64, error) {
+	switch source := source.(type) {
+	case *big.Rat:
+		if !source.IsInt() || !source.Num().IsInt64() {
+			return 0, fmt.Errorf("rational %s is not an integer", source.RatString())
+		}
+
+		return source.Num().Int64(), nil
+
+	case float32, float64:
+		var number, _ = toFloat(source)
+
+		if number != math.Trunc(number) || math.Abs(number) > math.MaxInt64 {
+			return 0, fmt.Errorf("%v is not an integer", number)
+		}
+
+		return int64(number), nil
+
+	case string:
+		return strconv.ParseInt(strings.TrimSpace(source), 10, 64)
+	}
+
+	if number, ok := toFloat(source); ok {
+		return int64(number), nil
+	}
+
+	return 0, fmt.Errorf("cannot convert %T to an integer", source)
+}
+
+func unmarshalField(property Property, tag *unmarshalTag, target reflect.Value) error {
+	var values = getUnmarshalValues(property, tag, target)
+
+	if !values.IsValid() || values.Len() == 0 {
+		return nil
+	}
+
+	return storeValues(values, target)
+}
+
+func unmarshalStruct(collection Collection, target reflect.Value, fieldErrors *[]*FieldError) error {
+	var targetType = target.Type()
+
+	for i := 0; i < targetType.NumField(); i++ {
+		var err error
+		var field = targetType.Field(i)
+		var property Property
+		var tag *unmarshalTag
+		var tagValue, ok = field.Tag.Lookup(unmarshalTagName)
+
+		if !ok {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				if err = unmarshalStruct(collection, target.Field(i), fieldErrors); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		if tagValue == "-" {
+			continue
+		}
+
+		if field.PkgPath != "" {
+			return fmt.Errorf("cannot unmarshal metadata into unexported field %s", field.Name)
+		}
+
+		if tag, err = parseUnmarshalTag(tagValue); err != nil {
+			return fmt.Errorf("invalid tag for field %s: %v", field.Name, err)
+		}
+
+		if property = getProperty(collection, tag.key); property == nil {
+			continue
+		}
+
+		if err = unmarshalField(property, tag, target.Field(i)); err != nil {
+			*fieldErrors = append(*fieldErrors, &FieldError{
+				Err:   err,
+				Field: field.Name,
+				Key:   tag.key,
+			})
+		}
+	}
+
+	return nil
+}
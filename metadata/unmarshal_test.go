@@ -0,0 +1,128 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+//
+// Public functions
+//
+
+func TestUnmarshal(t *testing.T) {
+	var collection, err = decodeCollection(newTestBuffer().data, newReadOptions(nil))
+	var target = unmarshalTestTarget{
+		Missing: "unchanged",
+	}
+
+	require.NoError(t, err)
+	require.NoError(t, Unmarshal(collection, &target))
+
+	require.Equal(t, []int{8, 8, 8}, target.BitsPerSample)
+	require.Equal(t, 8, *target.BitsPerSamplePointer)
+	require.Equal(t, time.Date(2020, time.March, 14, 0, 0, 0, 0, time.UTC), target.DateCreated)
+	require.Equal(t, uint8(8), target.FirstBitsPerSample)
+	require.Equal(t, 2.8, target.FNumber)
+	require.Equal(t, "F2.8", target.FNumberInterpreted)
+	require.Equal(t, 0, big.NewRat(28, 10).Cmp(target.FNumberRational))
+	require.Equal(t, "14/5", target.FNumberString)
+	require.Equal(t, "café", target.Keyword)
+	require.Equal(t, []string{"café", "two"}, target.Keywords)
+	require.Equal(t, "Canon", target.Make)
+	require.Equal(t, "unchanged", target.Missing)
+	require.Equal(t, []string{"one", "two"}, target.Subject)
+	require.Equal(t, "Title", target.Title)
+	require.Equal(t, "Titel", target.TitleDE)
+	require.Empty(t, target.TitleFR)
+	require.Equal(t, map[string]string{"x-default": "Title", "de": "Titel"}, target.Titles)
+	require.Empty(t, target.Untagged)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var collection, err = decodeCollection(newTestBuffer().data, newReadOptions(nil))
+	var target struct {
+		FNumber int     `ezif:"Exif.Photo.FNumber"`
+		Keyword int8    `ezif:"Iptc.Application2.Keywords"`
+		Make    float64 `ezif:"Exif.Image.Make"`
+		Title   string  `ezif:"Xmp.dc.title"`
+	}
+	var fieldErr *FieldError
+	var unmarshalErr *UnmarshalError
+
+	require.NoError(t, err)
+
+	target.FNumber = 4
+
+	err = Unmarshal(collection, &target)
+
+	// Fields that can be filled in still are, and fields that can't are left untouched.
+
+	require.True(t, errors.As(err, &unmarshalErr))
+	require.Len(t, unmarshalErr.Fields, 3)
+	require.Equal(t, "FNumber", unmarshalErr.Fields[0].Field)
+	require.Equal(t, "Exif.Photo.FNumber", unmarshalErr.Fields[0].Key)
+	require.Equal(t, "Keyword", unmarshalErr.Fields[1].Field)
+	require.Equal(t, "Make", unmarshalErr.Fields[2].Field)
+	require.True(t, errors.As(err, &fieldErr))
+	require.Equal(t, 4, target.FNumber)
+	require.Equal(t, "Title", target.Title)
+
+	// Errors that have nothing to do with values are reported on their own.
+
+	for name, v := range map[string]interface{}{
+		"InvalidKey": &struct {
+			Make string `ezif:"Make"`
+		}{},
+		"InvalidOption": &struct {
+			Make string `ezif:"Exif.Image.Make,raw"`
+		}{},
+		"NilPointer": (*unmarshalTestTarget)(nil),
+		"NotPointer": unmarshalTestTarget{},
+		"NotStruct":  new(string),
+		"Unexported": &struct {
+			make string `ezif:"Exif.Image.Make"`
+		}{},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var err = Unmarshal(collection, v)
+
+			require.Error(t, err)
+			require.False(t, errors.As(err, &unmarshalErr))
+		})
+	}
+}
+
+//
+// Private types
+//
+
+type unmarshalTestEmbedded struct {
+	Subject []string `ezif:"Xmp.dc.subject"`
+}
+
+type unmarshalTestTarget struct {
+	unmarshalTestEmbedded
+
+	BitsPerSample        []int             `ezif:"Exif.Image.BitsPerSample"`
+	BitsPerSamplePointer *int              `ezif:"Exif.Image.BitsPerSample"`
+	DateCreated          time.Time         `ezif:"Iptc.Application2.DateCreated"`
+	FirstBitsPerSample   uint8             `ezif:"Exif.Image.BitsPerSample"`
+	FNumber              float64           `ezif:"Exif.Photo.FNumber"`
+	FNumberInterpreted   string            `ezif:"Exif.Photo.FNumber,interpreted"`
+	FNumberRational      *big.Rat          `ezif:"Exif.Photo.FNumber"`
+	FNumberString        string            `ezif:"Exif.Photo.FNumber"`
+	Ignored              string            `ezif:"-"`
+	Keyword              string            `ezif:"Iptc.Application2.Keywords"`
+	Keywords             []string          `ezif:"Iptc.Application2.Keywords"`
+	Make                 string            `ezif:"Exif.Image.Make"`
+	Missing              string            `ezif:"Exif.Photo.ISOSpeedRatings"`
+	Title                string            `ezif:"Xmp.dc.title"`
+	TitleDE              string            `ezif:"Xmp.dc.title,lang=de"`
+	TitleFR              string            `ezif:"Xmp.dc.title,lang=fr"`
+	Titles               map[string]string `ezif:"Xmp.dc.title"`
+	Untagged             string
+}
@@ -0,0 +1,49 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"fmt"
+)
+
+//
+// Public types
+//
+
+// Severity is the severity of a warning reported by Exiv2.  The values of Severity match those of Exiv2::LogMsg::Level.
+type Severity int
+
+func (severity Severity) String() string {
+	switch severity {
+	case SeverityDebug:
+		return "debug"
+
+	case SeverityInfo:
+		return "info"
+
+	case SeverityWarning:
+		return "warning"
+
+	case SeverityError:
+		return "error"
+	}
+
+	return fmt.Sprintf("Severity(%d)", int(severity))
+}
+
+// Warning is a message reported by Exiv2 while reading an image (e.g., "Directory Canon has an unexpected next
+// pointer").  Warnings don't prevent an image from being read, but usually indicate that some of its metadata is
+// missing or malformed.
+type Warning struct {
+	Message  string
+	Severity Severity
+}
+
+//
+// Public constants
+//
+
+const (
+	SeverityDebug Severity = iota
+	SeverityInfo
+	SeverityWarning
+	SeverityError
+)
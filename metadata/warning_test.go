@@ -0,0 +1,19 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+//
+// Public functions
+//
+
+func TestSeverityString(t *testing.T) {
+	require.Equal(t, "debug", SeverityDebug.String())
+	require.Equal(t, "info", SeverityInfo.String())
+	require.Equal(t, "warning", SeverityWarning.String())
+	require.Equal(t, "error", SeverityError.String())
+	require.Equal(t, "Severity(7)", Severity(7).String())
+}
@@ -0,0 +1,192 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+/*
+#include <stdlib.h>
+
+#include "exiv2.h"
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.handcraftedbits.com/ezif/internal"
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+//
+// Public types
+//
+
+// PropertyDefinition describes a property belonging to a custom XMP namespace.
+type PropertyDefinition struct {
+	// Label is the human-readable label of the property.
+	Label string
+
+	// Name is the name of the property within its namespace (e.g., "license" for "Xmp.cc.license").
+	Name string
+
+	// TypeID is the type of the property, which must be one of the XMP type IDs.
+	TypeID types.ID
+}
+
+//
+// Public functions
+//
+
+// RegisterNamespace registers a custom XMP namespace with Exiv2 so that properties belonging to it are read using the
+// given prefix (e.g., properties in the "http://creativecommons.org/ns#" namespace registered with prefix "cc" will
+// appear as "Xmp.cc.*").  Optional property definitions can be provided in order to give properties in the namespace a
+// label and type, just like properties in the namespaces that Exiv2 knows about.  Registering a namespace that has
+// already been registered replaces its property definitions.  Since it isn't tied to a read, RegisterNamespace logs
+// using the default logger, like Initialize().
+func RegisterNamespace(prefix, uri string, definitions ...PropertyDefinition) error {
+	var cExiv2Error = C.struct_exiv2Error{
+		code: C.int(-999),
+	}
+	var cPrefix *C.char
+	var cURI *C.char
+	var definitionMap = make(map[string]PropertyDefinition)
+
+	if strings.TrimSpace(prefix) == "" || strings.TrimSpace(uri) == "" {
+		return fmt.Errorf("an XMP namespace requires both a prefix and a URI")
+	}
+
+	if err := Initialize(); err != nil {
+		return err
+	}
+
+	for _, definition := range definitions {
+		if !isXMPType(definition.TypeID) {
+			return fmt.Errorf("property '%s' has non-XMP type %s", definition.Name, definition.TypeID)
+		}
+
+		definitionMap[string(FamilyXMP)+"."+prefix+"."+definition.Name] = definition
+	}
+
+	cPrefix = C.CString(prefix)
+	cURI = C.CString(uri)
+
+	defer C.free(unsafe.Pointer(cPrefix))
+	defer C.free(unsafe.Pointer(cURI))
+
+	if internal.Log.Enabled(context.Background(), slog.LevelInfo) {
+		internal.Log.Info("registering XMP namespace",
+			"definitions", len(definitions),
+			"prefix", prefix,
+			"uri", uri,
+		)
+	}
+
+	C.registerXMPNamespace(cURI, cPrefix, &cExiv2Error)
+
+	if cExiv2Error.code != C.int(-999) {
+		defer C.free(unsafe.Pointer(cExiv2Error.message))
+
+		return newError(int(cExiv2Error.code), int(cExiv2Error.kind), C.GoString(cExiv2Error.message))
+	}
+
+	xmpDefinitionsMutex.Lock()
+
+	defer xmpDefinitionsMutex.Unlock()
+
+	for key := range xmpDefinitions {
+		if strings.HasPrefix(key, string(FamilyXMP)+"."+prefix+".") {
+			delete(xmpDefinitions, key)
+		}
+	}
+
+	for key, definition := range definitionMap {
+		xmpDefinitions[key] = definition
+	}
+
+	return nil
+}
+
+//
+// Private constants
+//
+
+const xmpLanguageDefault = "x-default"
+
+//
+// Private variables
+//
+
+var (
+	xmpDefinitions      = make(map[string]PropertyDefinition)
+	xmpDefinitionsMutex sync.RWMutex
+)
+
+//
+// Private functions
+//
+
+// applyXMPPropertyDefinition updates the label and type of an XMP property belonging to a custom namespace, converting
+// its value if necessary.
+func applyXMPPropertyDefinition(ctx context.Context, property *propertyImpl, logger *slog.Logger) {
+	var definition PropertyDefinition
+	var ok bool
+
+	xmpDefinitionsMutex.RLock()
+
+	definition, ok = xmpDefinitions[property.key()]
+
+	xmpDefinitionsMutex.RUnlock()
+
+	if !ok {
+		return
+	}
+
+	if definition.Label != "" {
+		property.label = definition.Label
+	}
+
+	if definition.TypeID == property.typeId {
+		return
+	}
+
+	switch {
+	// Simple and array XMP values are all exposed as string slices, so we just need to change the type.
+
+	case definition.TypeID != types.IDXMPLangAlt && property.typeId != types.IDXMPLangAlt:
+		property.typeId = definition.TypeID
+
+	// A simple value can be treated as the default language of a language alternative.
+
+	case definition.TypeID == types.IDXMPLangAlt && property.typeId == types.IDXMPText:
+		var value, ok = property.Value().([]string)
+
+		// A lazily decoded value that failed to decode is nil, in which case the property is left as is.
+
+		if !ok || len(value) == 0 {
+			return
+		}
+
+		property.typeId = definition.TypeID
+		property.value = []map[string]string{{xmpLanguageDefault: value[0]}}
+
+	default:
+		if logger.Enabled(ctx, slog.LevelDebug) {
+			logger.DebugContext(ctx, "ignoring incompatible XMP property type",
+				"actualTypeId", property.typeId,
+				"definedTypeId", definition.TypeID,
+				"name", property.key(),
+			)
+		}
+	}
+}
+
+func isXMPType(typeID types.ID) bool {
+	switch typeID {
+	case types.IDXMPAlt, types.IDXMPBag, types.IDXMPLangAlt, types.IDXMPSeq, types.IDXMPText:
+		return true
+	}
+
+	return false
+}
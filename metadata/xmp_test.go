@@ -0,0 +1,188 @@
+package metadata // import "golang.handcraftedbits.com/ezif/metadata"
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"golang.handcraftedbits.com/ezif/internal"
+	"golang.handcraftedbits.com/ezif/internal/testimage"
+	"golang.handcraftedbits.com/ezif/types"
+)
+
+//
+// Public functions
+//
+
+func TestApplyXMPPropertyDefinition(t *testing.T) {
+	var tests = []struct {
+		name   string
+		typeID types.ID
+		value  interface{}
+	}{
+		{
+			name:   "Text",
+			typeID: types.IDXMPLangAlt,
+			value:  []string{"Title"},
+		},
+		{
+			name:   "Empty",
+			typeID: types.IDXMPText,
+			value:  []string{},
+		},
+		{
+			name:   "Undecoded",
+			typeID: types.IDXMPText,
+		},
+	}
+
+	xmpDefinitionsMutex.Lock()
+
+	xmpDefinitions["Xmp.ezifTest.title"] = PropertyDefinition{
+		Name:   "title",
+		TypeID: types.IDXMPLangAlt,
+	}
+
+	xmpDefinitionsMutex.Unlock()
+
+	defer func() {
+		xmpDefinitionsMutex.Lock()
+
+		delete(xmpDefinitions, "Xmp.ezifTest.title")
+
+		xmpDefinitionsMutex.Unlock()
+	}()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var property = newProperty(FamilyXMP, "ezifTest", "title", types.IDXMPText, "", "", false)
+
+			property.value = test.value
+
+			applyXMPPropertyDefinition(context.Background(), property, internal.Log)
+
+			require.Equal(t, test.typeID, property.TypeID())
+
+			if test.typeID == types.IDXMPLangAlt {
+				require.Equal(t, []map[string]string{{xmpLanguageDefault: "Title"}}, property.Value())
+			}
+		})
+	}
+}
+
+func TestRegisterNamespace(t *testing.T) {
+	var collection Collection
+	var err error
+	var ok bool
+
+	require.Error(t, RegisterNamespace("", "http://example.com/ezif/"))
+	require.Error(t, RegisterNamespace("ezifTest", " "))
+	require.Error(t, RegisterNamespace("ezifTest", "http://example.com/ezif/", PropertyDefinition{
+		Name:   "rating",
+		TypeID: types.IDAsciiString,
+	}))
+
+	registerTestNamespace(t, PropertyDefinition{Label: "Rating", Name: "rating", TypeID: types.IDXMPText},
+		PropertyDefinition{Label: "Title", Name: "title", TypeID: types.IDXMPLangAlt})
+
+	// The Builder uses the definitions given to RegisterNamespace() instead of asking Exiv2.
+
+	collection, err = NewBuilder().
+		Set("Xmp.ezifTest.rating", "5").
+		Set("Xmp.ezifTest.title", map[string]string{xmpLanguageDefault: "Custom"}).
+		Build()
+
+	require.NoError(t, err)
+	require.Equal(t, "Rating", collection.XMP().Get("Xmp.ezifTest.rating").Label())
+	require.Equal(t, types.IDXMPText, collection.XMP().Get("Xmp.ezifTest.rating").TypeID())
+	require.Equal(t, "Title", collection.XMP().Get("Xmp.ezifTest.title").Label())
+	require.Equal(t, types.IDXMPLangAlt, collection.XMP().Get("Xmp.ezifTest.title").TypeID())
+
+	// Registering the namespace again replaces all of its definitions.
+
+	registerTestNamespace(t, PropertyDefinition{Label: "Ratings", Name: "rating", TypeID: types.IDXMPBag})
+
+	collection, err = NewBuilder().Set("Xmp.ezifTest.rating", []string{"4", "5"}).Build()
+
+	require.NoError(t, err)
+	require.Equal(t, "Ratings", collection.XMP().Get("Xmp.ezifTest.rating").Label())
+	require.Equal(t, types.IDXMPBag, collection.XMP().Get("Xmp.ezifTest.rating").TypeID())
+
+	xmpDefinitionsMutex.RLock()
+
+	_, ok = xmpDefinitions["Xmp.ezifTest.title"]
+
+	xmpDefinitionsMutex.RUnlock()
+
+	require.False(t, ok)
+}
+
+func TestRegisterNamespaceRead(t *testing.T) {
+	var collection Collection
+	var data = (&testimage.Image{
+		XMP: testimage.XMPPacket(`<other:rating xmlns:other="http://example.com/ezif/">5</other:rating>
+   <other:title xmlns:other="http://example.com/ezif/">Custom</other:title>`),
+	}).JPEG()
+	var err error
+	var property Property
+
+	registerTestNamespace(t, PropertyDefinition{Label: "Rating", Name: "rating", TypeID: types.IDXMPText},
+		PropertyDefinition{Label: "Title", Name: "title", TypeID: types.IDXMPLangAlt})
+
+	// Properties are read using the registered prefix rather than the one used by the image.
+
+	collection, err = FromBytes(data)
+
+	require.NoError(t, err)
+
+	property = collection.XMP().Get("Xmp.ezifTest.title")
+
+	require.NotNil(t, property)
+	require.Equal(t, "Title", property.Label())
+	require.Equal(t, types.IDXMPLangAlt, property.TypeID())
+	require.Equal(t, []map[string]string{{xmpLanguageDefault: "Custom"}}, property.Value())
+
+	property = collection.XMP().Get("Xmp.ezifTest.rating")
+
+	require.NotNil(t, property)
+	require.Equal(t, "Rating", property.Label())
+	require.Equal(t, types.IDXMPText, property.TypeID())
+	require.Equal(t, []string{"5"}, property.Value())
+
+	// Once its definition is replaced, the title is read as the simple value found in the image.
+
+	registerTestNamespace(t, PropertyDefinition{Label: "Ratings", Name: "rating", TypeID: types.IDXMPBag})
+
+	collection, err = FromBytes(data)
+
+	require.NoError(t, err)
+
+	property = collection.XMP().Get("Xmp.ezifTest.title")
+
+	require.NotNil(t, property)
+	require.NotEqual(t, "Title", property.Label())
+	require.Equal(t, types.IDXMPText, property.TypeID())
+	require.Equal(t, []string{"Custom"}, property.Value())
+
+	property = collection.XMP().Get("Xmp.ezifTest.rating")
+
+	require.NotNil(t, property)
+	require.Equal(t, "Ratings", property.Label())
+	require.Equal(t, types.IDXMPBag, property.TypeID())
+	require.Equal(t, []string{"5"}, property.Value())
+}
+
+//
+// Private functions
+//
+
+// registerTestNamespace registers the "ezifTest" XMP namespace with the given definitions, which are removed once the
+// test is done.
+func registerTestNamespace(t *testing.T, definitions ...PropertyDefinition) {
+	require.NoError(t, RegisterNamespace("ezifTest", "http://example.com/ezif/", definitions...))
+
+	t.Cleanup(func() {
+		_ = RegisterNamespace("ezifTest", "http://example.com/ezif/")
+	})
+}
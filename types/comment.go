@@ -0,0 +1,218 @@
+package types // import "golang.handcraftedbits.com/ezif/types"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+//
+// Public types
+//
+
+// Comment is a character set aware comment, as stored in the Exif.Photo.UserComment and Exif.GPSInfo.GPSAreaInformation
+// properties.
+type Comment interface {
+	fmt.Stringer
+
+	Charset() CommentCharset
+	Text() string
+}
+
+// CommentCharset is the character set declared by the 8-byte header of a Comment.
+type CommentCharset int
+
+func (charset CommentCharset) String() string {
+	switch charset {
+	case CommentCharsetASCII:
+		return "Ascii"
+
+	case CommentCharsetJIS:
+		return "Jis"
+
+	case CommentCharsetUnicode:
+		return "Unicode"
+	}
+
+	return "Undefined"
+}
+
+//
+// Public constants
+//
+
+const (
+	CommentCharsetUndefined CommentCharset = 0
+	CommentCharsetASCII     CommentCharset = 1
+	CommentCharsetJIS       CommentCharset = 2
+	CommentCharsetUnicode   CommentCharset = 3
+)
+
+//
+// Public functions
+//
+
+// NewComment creates a comment from text that was already decoded (e.g., by Exiv2, which decodes comments into UTF-8
+// strings).  Since the original character set is no longer known, it is reported as ASCII if the text only contains
+// ASCII characters and Unicode otherwise.
+func NewComment(text string) Comment {
+	var charset = CommentCharsetASCII
+
+	for _, value := range text {
+		if value >= utf8.RuneSelf {
+			charset = CommentCharsetUnicode
+
+			break
+		}
+	}
+
+	return &commentImpl{
+		charset: charset,
+		text:    text,
+	}
+}
+
+// ParseComment decodes the raw bytes of a comment, which consist of an 8-byte character set header followed by the
+// comment text.  Unicode comments are UCS-2 encoded using the byte order of the image, unless a byte order mark is
+// present.  JIS comments are only supported when they contain no characters outside of JIS X 0201 Roman (i.e., ASCII).
+func ParseComment(data []byte, byteOrder binary.ByteOrder) (Comment, error) {
+	var charset CommentCharset
+	var text string
+
+	if len(data) < commentHeaderLength {
+		return nil, fmt.Errorf("comment must be at least %d bytes long, found %d bytes", commentHeaderLength,
+			len(data))
+	}
+
+	for headerCharset, header := range commentHeaders {
+		if bytes.Equal(data[:commentHeaderLength], header) {
+			charset = headerCharset
+
+			break
+		}
+	}
+
+	if charset == CommentCharsetUndefined && !bytes.Equal(data[:commentHeaderLength],
+		commentHeaders[CommentCharsetUndefined]) {
+		return nil, fmt.Errorf("unknown comment character set '%s'",
+			string(bytes.TrimRight(data[:commentHeaderLength], "\x00")))
+	}
+
+	data = data[commentHeaderLength:]
+
+	switch charset {
+	case CommentCharsetJIS:
+		for _, value := range data {
+			if value >= 0x80 || value == 0x1B {
+				return nil, fmt.Errorf("JIS X 0208 encoded comments are not supported")
+			}
+		}
+
+		text = trimNulTerminated(data)
+
+	case CommentCharsetUnicode:
+		text = decodeUCS2(data, byteOrder)
+
+	default:
+		text = trimNulTerminated(data)
+	}
+
+	return &commentImpl{
+		charset: charset,
+		text:    text,
+	}, nil
+}
+
+// ParseXPString decodes the raw bytes of one of the Windows Explorer properties (e.g., Exif.Image.XPComment or
+// Exif.Image.XPKeywords), which are always UCS-2 encoded in little endian byte order regardless of the byte order of
+// the image.
+func ParseXPString(data []byte) (string, error) {
+	if len(data)%2 != 0 {
+		return "", fmt.Errorf("UCS-2 string must have an even number of bytes, found %d bytes", len(data))
+	}
+
+	return decodeUCS2(data, binary.LittleEndian), nil
+}
+
+//
+// Private types
+//
+
+// Comment implementation
+type commentImpl struct {
+	charset CommentCharset
+	text    string
+}
+
+func (comment *commentImpl) Charset() CommentCharset {
+	return comment.charset
+}
+
+func (comment *commentImpl) String() string {
+	return comment.text
+}
+
+func (comment *commentImpl) Text() string {
+	return comment.text
+}
+
+//
+// Private constants
+//
+
+const commentHeaderLength = 8
+
+//
+// Private variables
+//
+
+var commentHeaders = map[CommentCharset][]byte{
+	CommentCharsetASCII:     []byte("ASCII\x00\x00\x00"),
+	CommentCharsetJIS:       []byte("JIS\x00\x00\x00\x00\x00"),
+	CommentCharsetUndefined: []byte("\x00\x00\x00\x00\x00\x00\x00\x00"),
+	CommentCharsetUnicode:   []byte("UNICODE\x00"),
+}
+
+//
+// Private functions
+//
+
+func decodeUCS2(data []byte, byteOrder binary.ByteOrder) string {
+	var units = make([]uint16, 0, len(data)/2)
+
+	// Honor a byte order mark if one is present, since plenty of software ignores the byte order of the image.
+
+	if len(data) >= 2 {
+		switch {
+		case data[0] == 0xFE && data[1] == 0xFF:
+			byteOrder = binary.BigEndian
+			data = data[2:]
+
+		case data[0] == 0xFF && data[1] == 0xFE:
+			byteOrder = binary.LittleEndian
+			data = data[2:]
+		}
+	}
+
+	for i := 0; i+1 < len(data); i += 2 {
+		var unit = byteOrder.Uint16(data[i:])
+
+		if unit == 0 {
+			break
+		}
+
+		units = append(units, unit)
+	}
+
+	return string(utf16.Decode(units))
+}
+
+func trimNulTerminated(data []byte) string {
+	if index := bytes.IndexByte(data, 0); index != -1 {
+		return string(data[:index])
+	}
+
+	return string(data)
+}
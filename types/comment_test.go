@@ -0,0 +1,174 @@
+package types // import "golang.handcraftedbits.com/ezif/types"
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+//
+// Public functions
+//
+
+func TestNewComment(t *testing.T) {
+	var tests = []struct {
+		charset CommentCharset
+		name    string
+		text    string
+	}{
+		{
+			charset: CommentCharsetASCII,
+			name:    "ASCII",
+			text:    "Hello",
+		},
+		{
+			charset: CommentCharsetASCII,
+			name:    "Empty",
+			text:    "",
+		},
+		{
+			charset: CommentCharsetUnicode,
+			name:    "Unicode",
+			text:    "Héllo",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var comment = NewComment(test.text)
+
+			require.Equal(t, test.charset, comment.Charset())
+			require.Equal(t, test.text, comment.Text())
+			require.Equal(t, test.text, comment.String())
+		})
+	}
+}
+
+func TestParseComment(t *testing.T) {
+	var tests = []struct {
+		byteOrder binary.ByteOrder
+		charset   CommentCharset
+		data      []byte
+		err       string
+		name      string
+		text      string
+	}{
+		{
+			byteOrder: binary.LittleEndian,
+			charset:   CommentCharsetASCII,
+			data:      []byte("ASCII\x00\x00\x00Hello\x00\x00"),
+			name:      "ASCII",
+			text:      "Hello",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			charset:   CommentCharsetUndefined,
+			data:      []byte("\x00\x00\x00\x00\x00\x00\x00\x00Hello"),
+			name:      "Undefined",
+			text:      "Hello",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			charset:   CommentCharsetJIS,
+			data:      []byte("JIS\x00\x00\x00\x00\x00Hello"),
+			name:      "JIS",
+			text:      "Hello",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte("JIS\x00\x00\x00\x00\x00\x1B$B"),
+			err:       "JIS X 0208 encoded comments are not supported",
+			name:      "JISX0208",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			charset:   CommentCharsetUnicode,
+			data:      []byte("UNICODE\x00H\x00\xE9\x00\x00\x00"),
+			name:      "UnicodeLittleEndian",
+			text:      "Hé",
+		},
+		{
+			byteOrder: binary.BigEndian,
+			charset:   CommentCharsetUnicode,
+			data:      []byte("UNICODE\x00\x00H\x00\xE9"),
+			name:      "UnicodeBigEndian",
+			text:      "Hé",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			charset:   CommentCharsetUnicode,
+			data:      []byte("UNICODE\x00\xFE\xFF\x00H\x00\xE9"),
+			name:      "UnicodeByteOrderMark",
+			text:      "Hé",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte("ASCII"),
+			err:       "comment must be at least 8 bytes long, found 5 bytes",
+			name:      "TruncatedHeader",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte("EBCDIC\x00\x00Hello"),
+			err:       "unknown comment character set 'EBCDIC'",
+			name:      "UnknownCharset",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var comment, err = ParseComment(test.data, test.byteOrder)
+
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.charset, comment.Charset())
+			require.Equal(t, test.text, comment.Text())
+		})
+	}
+}
+
+func TestParseXPString(t *testing.T) {
+	var tests = []struct {
+		data []byte
+		err  string
+		name string
+		text string
+	}{
+		{
+			data: []byte{'H', 0x00, 0xE9, 0x00, 0x00, 0x00},
+			name: "Terminated",
+			text: "Hé",
+		},
+		{
+			data: []byte{'H', 0x00, 'i', 0x00},
+			name: "Unterminated",
+			text: "Hi",
+		},
+		{
+			data: []byte{'H', 0x00, 'i'},
+			err:  "UCS-2 string must have an even number of bytes, found 3 bytes",
+			name: "OddLength",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var text, err = ParseXPString(test.data)
+
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.text, text)
+		})
+	}
+}
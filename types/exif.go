@@ -0,0 +1,552 @@
+package types // import "golang.handcraftedbits.com/ezif/types"
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+//
+// Public types
+//
+
+// CFAColor is a color filter found in a CFAPattern.
+type CFAColor int
+
+func (color CFAColor) String() string {
+	switch color {
+	case CFAColorRed:
+		return "Red"
+
+	case CFAColorGreen:
+		return "Green"
+
+	case CFAColorBlue:
+		return "Blue"
+
+	case CFAColorCyan:
+		return "Cyan"
+
+	case CFAColorMagenta:
+		return "Magenta"
+
+	case CFAColorYellow:
+		return "Yellow"
+
+	case CFAColorWhite:
+		return "White"
+	}
+
+	return "Unknown"
+}
+
+// CFAPattern is the color filter array geometric pattern of an image sensor, as stored in the Exif.Photo.CFAPattern
+// property.
+type CFAPattern interface {
+	fmt.Stringer
+
+	Color(row, column int) CFAColor
+	Columns() int
+	Rows() int
+}
+
+// Component is a single channel of the ComponentsConfiguration Exif property.
+type Component int
+
+func (component Component) String() string {
+	switch component {
+	case ComponentY:
+		return "Y"
+
+	case ComponentCb:
+		return "Cb"
+
+	case ComponentCr:
+		return "Cr"
+
+	case ComponentR:
+		return "R"
+
+	case ComponentG:
+		return "G"
+
+	case ComponentB:
+		return "B"
+	}
+
+	return "-"
+}
+
+// ComponentsConfiguration describes the channels of compressed image data, as stored in the
+// Exif.Photo.ComponentsConfiguration property.
+type ComponentsConfiguration interface {
+	fmt.Stringer
+
+	Components() []Component
+}
+
+// DeviceSettingDescription describes the picture-taking conditions of a particular camera model, as stored in the
+// Exif.Photo.DeviceSettingDescription property.
+type DeviceSettingDescription interface {
+	fmt.Stringer
+
+	Columns() int
+	Rows() int
+	Setting(row, column int) string
+}
+
+// OECF is the opto-electric conversion function of an image sensor, as stored in the Exif.Image.OECF and
+// Exif.Photo.OECF properties.
+type OECF interface {
+	fmt.Stringer
+
+	ColumnName(column int) string
+	Columns() int
+	Rows() int
+	Value(row, column int) *big.Rat
+}
+
+// SpatialFrequencyResponse is the spatial frequency table and SFR values of an image sensor, as stored in the
+// Exif.Image.SpatialFrequencyResponse and Exif.Photo.SpatialFrequencyResponse properties.
+type SpatialFrequencyResponse interface {
+	fmt.Stringer
+
+	ColumnName(column int) string
+	Columns() int
+	Rows() int
+	Value(row, column int) *big.Rat
+}
+
+// Version is a four character version number, as stored in the Exif.Photo.ExifVersion and Exif.Photo.FlashpixVersion
+// properties.
+type Version interface {
+	fmt.Stringer
+
+	Major() int
+	Minor() int
+}
+
+//
+// Public constants
+//
+
+const (
+	CFAColorRed     CFAColor = 0
+	CFAColorGreen   CFAColor = 1
+	CFAColorBlue    CFAColor = 2
+	CFAColorCyan    CFAColor = 3
+	CFAColorMagenta CFAColor = 4
+	CFAColorYellow  CFAColor = 5
+	CFAColorWhite   CFAColor = 6
+)
+
+const (
+	ComponentNone Component = 0
+	ComponentY    Component = 1
+	ComponentCb   Component = 2
+	ComponentCr   Component = 3
+	ComponentR    Component = 4
+	ComponentG    Component = 5
+	ComponentB    Component = 6
+)
+
+//
+// Public functions
+//
+
+// ParseCFAPattern decodes the raw bytes of an Exif.Photo.CFAPattern property.
+func ParseCFAPattern(data []byte, byteOrder binary.ByteOrder) (CFAPattern, error) {
+	var columns, rows int
+	var err error
+	var reader = newExifReader(data, byteOrder)
+
+	if columns, rows, err = reader.readDimensions(); err != nil {
+		return nil, err
+	}
+
+	if reader.remaining() < columns*rows {
+		return nil, fmt.Errorf("CFA pattern requires %d values but only %d bytes remain", columns*rows,
+			reader.remaining())
+	}
+
+	var colors = make([]CFAColor, columns*rows)
+
+	for i := range colors {
+		colors[i] = CFAColor(reader.data[reader.offset+i])
+	}
+
+	return &cfaPatternImpl{
+		colors:  colors,
+		columns: columns,
+		rows:    rows,
+	}, nil
+}
+
+// ParseComponentsConfiguration decodes the raw bytes of an Exif.Photo.ComponentsConfiguration property.
+func ParseComponentsConfiguration(data []byte) (ComponentsConfiguration, error) {
+	if len(data) != 4 {
+		return nil, fmt.Errorf("components configuration must be 4 bytes long, found %d bytes", len(data))
+	}
+
+	var components = make([]Component, len(data))
+
+	for i, value := range data {
+		components[i] = Component(value)
+	}
+
+	return &componentsConfigurationImpl{
+		components: components,
+	}, nil
+}
+
+// ParseDeviceSettingDescription decodes the raw bytes of an Exif.Photo.DeviceSettingDescription property.  Setting
+// strings are UCS-2 encoded using the byte order of the image.
+func ParseDeviceSettingDescription(data []byte, byteOrder binary.ByteOrder) (DeviceSettingDescription, error) {
+	var columns, rows int
+	var err error
+	var reader = newExifReader(data, byteOrder)
+
+	if columns, rows, err = reader.readDimensions(); err != nil {
+		return nil, err
+	}
+
+	// Each setting is at least a terminating UCS-2 character, which keeps a corrupt header from causing a huge
+	// allocation.
+
+	if reader.remaining()/2 < columns*rows {
+		return nil, fmt.Errorf("device setting description requires %d settings but only %d bytes remain",
+			columns*rows, reader.remaining())
+	}
+
+	var settings = make([]string, columns*rows)
+
+	for i := range settings {
+		if settings[i], err = reader.readUCS2String(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &deviceSettingDescriptionImpl{
+		columns:  columns,
+		rows:     rows,
+		settings: settings,
+	}, nil
+}
+
+// ParseOECF decodes the raw bytes of an Exif.Image.OECF or Exif.Photo.OECF property.
+func ParseOECF(data []byte, byteOrder binary.ByteOrder) (OECF, error) {
+	var table, err = parseRationalTable(data, byteOrder, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+// ParseSpatialFrequencyResponse decodes the raw bytes of an Exif.Image.SpatialFrequencyResponse or
+// Exif.Photo.SpatialFrequencyResponse property.
+func ParseSpatialFrequencyResponse(data []byte, byteOrder binary.ByteOrder) (SpatialFrequencyResponse, error) {
+	var table, err = parseRationalTable(data, byteOrder, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+// ParseVersion decodes the raw bytes of an Exif.Photo.ExifVersion or Exif.Photo.FlashpixVersion property.
+func ParseVersion(data []byte) (Version, error) {
+	var err error
+	var major, minor int
+
+	if len(data) != 4 {
+		return nil, fmt.Errorf("version must be 4 bytes long, found %d bytes", len(data))
+	}
+
+	if major, err = strconv.Atoi(string(data[:2])); err != nil {
+		return nil, fmt.Errorf("invalid major version '%s'", string(data[:2]))
+	}
+
+	if minor, err = strconv.Atoi(string(data[2:])); err != nil {
+		return nil, fmt.Errorf("invalid minor version '%s'", string(data[2:]))
+	}
+
+	return &versionImpl{
+		major: major,
+		minor: minor,
+	}, nil
+}
+
+//
+// Private types
+//
+
+// CFAPattern implementation
+type cfaPatternImpl struct {
+	colors  []CFAColor
+	columns int
+	rows    int
+}
+
+func (pattern *cfaPatternImpl) Color(row, column int) CFAColor {
+	return pattern.colors[(row*pattern.columns)+column]
+}
+
+func (pattern *cfaPatternImpl) Columns() int {
+	return pattern.columns
+}
+
+func (pattern *cfaPatternImpl) Rows() int {
+	return pattern.rows
+}
+
+func (pattern *cfaPatternImpl) String() string {
+	var rows = make([]string, pattern.rows)
+
+	for row := 0; row < pattern.rows; row++ {
+		var colors = make([]string, pattern.columns)
+
+		for column := 0; column < pattern.columns; column++ {
+			colors[column] = pattern.Color(row, column).String()
+		}
+
+		rows[row] = strings.Join(colors, ",")
+	}
+
+	return "[" + strings.Join(rows, "][") + "]"
+}
+
+// ComponentsConfiguration implementation
+type componentsConfigurationImpl struct {
+	components []Component
+}
+
+func (configuration *componentsConfigurationImpl) Components() []Component {
+	return configuration.components
+}
+
+func (configuration *componentsConfigurationImpl) String() string {
+	var builder strings.Builder
+
+	for _, component := range configuration.components {
+		if component != ComponentNone {
+			builder.WriteString(component.String())
+		}
+	}
+
+	return builder.String()
+}
+
+// DeviceSettingDescription implementation
+type deviceSettingDescriptionImpl struct {
+	columns  int
+	rows     int
+	settings []string
+}
+
+func (description *deviceSettingDescriptionImpl) Columns() int {
+	return description.columns
+}
+
+func (description *deviceSettingDescriptionImpl) Rows() int {
+	return description.rows
+}
+
+func (description *deviceSettingDescriptionImpl) Setting(row, column int) string {
+	return description.settings[(row*description.columns)+column]
+}
+
+func (description *deviceSettingDescriptionImpl) String() string {
+	return strings.Join(description.settings, ", ")
+}
+
+// OECF and SpatialFrequencyResponse implementation
+type rationalTableImpl struct {
+	columnNames []string
+	rows        int
+	values      []*big.Rat
+}
+
+func (table *rationalTableImpl) ColumnName(column int) string {
+	return table.columnNames[column]
+}
+
+func (table *rationalTableImpl) Columns() int {
+	return len(table.columnNames)
+}
+
+func (table *rationalTableImpl) Rows() int {
+	return table.rows
+}
+
+func (table *rationalTableImpl) String() string {
+	var values = make([]string, len(table.values))
+
+	for i, value := range table.values {
+		values[i] = value.RatString()
+	}
+
+	return fmt.Sprintf("%s: %s", strings.Join(table.columnNames, ", "), strings.Join(values, " "))
+}
+
+func (table *rationalTableImpl) Value(row, column int) *big.Rat {
+	return table.values[(row*len(table.columnNames))+column]
+}
+
+// Version implementation
+type versionImpl struct {
+	major int
+	minor int
+}
+
+func (version *versionImpl) Major() int {
+	return version.major
+}
+
+func (version *versionImpl) Minor() int {
+	return version.minor
+}
+
+func (version *versionImpl) String() string {
+	return fmt.Sprintf("%d.%02d", version.major, version.minor)
+}
+
+// exifReader is a simple cursor over the raw bytes of an Exif property that has a structured layout.
+type exifReader struct {
+	byteOrder binary.ByteOrder
+	data      []byte
+	offset    int
+}
+
+func (reader *exifReader) readASCIIString() (string, error) {
+	for i := reader.offset; i < len(reader.data); i++ {
+		if reader.data[i] == 0 {
+			var result = string(reader.data[reader.offset:i])
+
+			reader.offset = i + 1
+
+			return result, nil
+		}
+	}
+
+	return "", fmt.Errorf("unterminated string at offset %d", reader.offset)
+}
+
+func (reader *exifReader) readDimensions() (int, int, error) {
+	var columns, rows uint16
+
+	if reader.remaining() < 4 {
+		return 0, 0, fmt.Errorf("expected at least 4 bytes for dimensions, found %d bytes", reader.remaining())
+	}
+
+	columns = reader.byteOrder.Uint16(reader.data[reader.offset:])
+	rows = reader.byteOrder.Uint16(reader.data[reader.offset+2:])
+
+	reader.offset += 4
+
+	return int(columns), int(rows), nil
+}
+
+func (reader *exifReader) readRational(signed bool) (*big.Rat, error) {
+	var denominator, numerator int64
+
+	if reader.remaining() < 8 {
+		return nil, fmt.Errorf("expected 8 bytes for rational value at offset %d, found %d bytes", reader.offset,
+			reader.remaining())
+	}
+
+	if signed {
+		numerator = int64(int32(reader.byteOrder.Uint32(reader.data[reader.offset:])))
+		denominator = int64(int32(reader.byteOrder.Uint32(reader.data[reader.offset+4:])))
+	} else {
+		numerator = int64(reader.byteOrder.Uint32(reader.data[reader.offset:]))
+		denominator = int64(reader.byteOrder.Uint32(reader.data[reader.offset+4:]))
+	}
+
+	reader.offset += 8
+
+	// A zero denominator would cause big.NewRat() to panic, and it's not unheard of for cameras to write one.
+
+	if denominator == 0 {
+		return nil, fmt.Errorf("rational value at offset %d has a zero denominator", reader.offset-8)
+	}
+
+	return big.NewRat(numerator, denominator), nil
+}
+
+func (reader *exifReader) readUCS2String() (string, error) {
+	var units []uint16
+
+	for reader.remaining() >= 2 {
+		var unit = reader.byteOrder.Uint16(reader.data[reader.offset:])
+
+		reader.offset += 2
+
+		if unit == 0 {
+			return string(utf16.Decode(units)), nil
+		}
+
+		units = append(units, unit)
+	}
+
+	return "", fmt.Errorf("unterminated UCS-2 string at offset %d", reader.offset)
+}
+
+func (reader *exifReader) remaining() int {
+	return len(reader.data) - reader.offset
+}
+
+//
+// Private functions
+//
+
+func newExifReader(data []byte, byteOrder binary.ByteOrder) *exifReader {
+	return &exifReader{
+		byteOrder: byteOrder,
+		data:      data,
+	}
+}
+
+func parseRationalTable(data []byte, byteOrder binary.ByteOrder, signed bool) (*rationalTableImpl, error) {
+	var columns, rows int
+	var err error
+	var reader = newExifReader(data, byteOrder)
+
+	if columns, rows, err = reader.readDimensions(); err != nil {
+		return nil, err
+	}
+
+	// Each column name is at least a terminating NUL character and each value is 8 bytes long, which keeps a corrupt
+	// header from causing a huge allocation.
+
+	if reader.remaining() < columns || (reader.remaining()-columns)/8 < columns*rows {
+		return nil, fmt.Errorf("table requires %d column names and %d values but only %d bytes remain", columns,
+			columns*rows, reader.remaining())
+	}
+
+	var columnNames = make([]string, columns)
+	var values = make([]*big.Rat, columns*rows)
+
+	for i := range columnNames {
+		if columnNames[i], err = reader.readASCIIString(); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range values {
+		if values[i], err = reader.readRational(signed); err != nil {
+			return nil, err
+		}
+	}
+
+	return &rationalTableImpl{
+		columnNames: columnNames,
+		rows:        rows,
+		values:      values,
+	}, nil
+}
@@ -0,0 +1,367 @@
+package types // import "golang.handcraftedbits.com/ezif/types"
+
+import (
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+//
+// Public functions
+//
+
+func TestParseCFAPattern(t *testing.T) {
+	var tests = []struct {
+		byteOrder binary.ByteOrder
+		data      []byte
+		err       string
+		name      string
+		str       string
+	}{
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte{0x02, 0x00, 0x02, 0x00, 0x00, 0x01, 0x01, 0x02},
+			name:      "LittleEndian",
+			str:       "[Red,Green][Green,Blue]",
+		},
+		{
+			byteOrder: binary.BigEndian,
+			data:      []byte{0x00, 0x02, 0x00, 0x01, 0x03, 0x04},
+			name:      "BigEndian",
+			str:       "[Cyan,Magenta]",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte{0x02, 0x00},
+			err:       "expected at least 4 bytes for dimensions, found 2 bytes",
+			name:      "TruncatedHeader",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte{0x02, 0x00, 0x02, 0x00, 0x00, 0x01, 0x01},
+			err:       "CFA pattern requires 4 values but only 3 bytes remain",
+			name:      "TruncatedValues",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte{0xFF, 0xFF, 0xFF, 0xFF},
+			err:       "CFA pattern requires 4294836225 values but only 0 bytes remain",
+			name:      "OversizedHeader",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var pattern, err = ParseCFAPattern(test.data, test.byteOrder)
+
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.str, pattern.String())
+		})
+	}
+}
+
+func TestParseComponentsConfiguration(t *testing.T) {
+	var tests = []struct {
+		data []byte
+		err  string
+		name string
+		str  string
+	}{
+		{
+			data: []byte{0x01, 0x02, 0x03, 0x00},
+			name: "YCbCr",
+			str:  "YCbCr",
+		},
+		{
+			data: []byte{0x04, 0x05, 0x06, 0x00},
+			name: "RGB",
+			str:  "RGB",
+		},
+		{
+			data: []byte{0x01, 0x02, 0x03},
+			err:  "components configuration must be 4 bytes long, found 3 bytes",
+			name: "Truncated",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var configuration, err = ParseComponentsConfiguration(test.data)
+
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.str, configuration.String())
+		})
+	}
+}
+
+func TestParseDeviceSettingDescription(t *testing.T) {
+	var tests = []struct {
+		byteOrder binary.ByteOrder
+		data      []byte
+		err       string
+		name      string
+		settings  []string
+	}{
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte{0x02, 0x00, 0x01, 0x00, 'A', 0x00, 0x00, 0x00, 0xE9, 0x00, 0x00, 0x00},
+			name:      "LittleEndian",
+			settings:  []string{"A", "é"},
+		},
+		{
+			byteOrder: binary.BigEndian,
+			data:      []byte{0x00, 0x01, 0x00, 0x01, 0x00, 'O', 0x00, 'K', 0x00, 0x00},
+			name:      "BigEndian",
+			settings:  []string{"OK"},
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte{0x01, 0x00},
+			err:       "expected at least 4 bytes for dimensions, found 2 bytes",
+			name:      "TruncatedHeader",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte{0x01, 0x00, 0x01, 0x00, 'A', 0x00, 'B', 0x00},
+			err:       "unterminated UCS-2 string at offset 8",
+			name:      "UnterminatedSetting",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte{0x02, 0x00, 0x02, 0x00, 'A', 0x00, 0x00, 0x00},
+			err:       "device setting description requires 4 settings but only 4 bytes remain",
+			name:      "TruncatedSettings",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte{0xFF, 0xFF, 0xFF, 0xFF},
+			err:       "device setting description requires 4294836225 settings but only 0 bytes remain",
+			name:      "OversizedHeader",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var description, err = ParseDeviceSettingDescription(test.data, test.byteOrder)
+
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, len(test.settings), description.Columns()*description.Rows())
+
+			for i, setting := range test.settings {
+				require.Equal(t, setting, description.Setting(i/description.Columns(), i%description.Columns()))
+			}
+		})
+	}
+}
+
+func TestParseOECF(t *testing.T) {
+	var tests = []struct {
+		byteOrder   binary.ByteOrder
+		columnNames []string
+		data        []byte
+		err         string
+		name        string
+		values      []*big.Rat
+	}{
+		{
+			byteOrder:   binary.LittleEndian,
+			columnNames: []string{"a"},
+			data: []byte{0x01, 0x00, 0x02, 0x00, 'a', 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0xFF,
+				0xFF, 0xFF, 0xFF, 0x04, 0x00, 0x00, 0x00},
+			name:   "LittleEndian",
+			values: []*big.Rat{big.NewRat(1, 2), big.NewRat(-1, 4)},
+		},
+		{
+			byteOrder:   binary.BigEndian,
+			columnNames: []string{"a", "b"},
+			data: []byte{0x00, 0x02, 0x00, 0x01, 'a', 0x00, 'b', 0x00, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00,
+				0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x03},
+			name:   "BigEndian",
+			values: []*big.Rat{big.NewRat(3, 1), big.NewRat(1, 3)},
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte{0x01, 0x00, 0x01},
+			err:       "expected at least 4 bytes for dimensions, found 3 bytes",
+			name:      "TruncatedHeader",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte{0x01, 0x00, 0x01, 0x00, 'a', 0x00, 0x01, 0x00, 0x00, 0x00},
+			err:       "table requires 1 column names and 1 values but only 6 bytes remain",
+			name:      "TruncatedValues",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			data: []byte{0x01, 0x00, 0x01, 0x00, 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', 'k', 'l', 'm',
+				'n'},
+			err:  "unterminated string at offset 4",
+			name: "UnterminatedColumnName",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			data: []byte{0x01, 0x00, 0x01, 0x00, 'a', 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00},
+			err:  "rational value at offset 6 has a zero denominator",
+			name: "ZeroDenominator",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte{0xFF, 0xFF, 0xFF, 0xFF},
+			err:       "table requires 65535 column names and 4294836225 values but only 0 bytes remain",
+			name:      "OversizedHeader",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var oecf, err = ParseOECF(test.data, test.byteOrder)
+
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, len(test.columnNames), oecf.Columns())
+			require.Equal(t, len(test.values), oecf.Columns()*oecf.Rows())
+
+			for i, columnName := range test.columnNames {
+				require.Equal(t, columnName, oecf.ColumnName(i))
+			}
+
+			for i, value := range test.values {
+				require.Zero(t, value.Cmp(oecf.Value(i/oecf.Columns(), i%oecf.Columns())))
+			}
+		})
+	}
+}
+
+func TestParseSpatialFrequencyResponse(t *testing.T) {
+	var tests = []struct {
+		byteOrder binary.ByteOrder
+		data      []byte
+		err       string
+		name      string
+		str       string
+	}{
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte{0x01, 0x00, 0x01, 0x00, 'a', 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0x02, 0x00, 0x00, 0x00},
+			name:      "Unsigned",
+			str:       "a: 4294967295/2",
+		},
+		{
+			byteOrder: binary.LittleEndian,
+			data:      []byte{0x00, 0x00, 0x00},
+			err:       "expected at least 4 bytes for dimensions, found 3 bytes",
+			name:      "TruncatedHeader",
+		},
+		{
+			byteOrder: binary.BigEndian,
+			data:      []byte{0x00, 0x02, 0x00, 0x02, 'a', 0x00, 'b', 0x00, 0x00, 0x00, 0x00, 0x01},
+			err:       "table requires 2 column names and 4 values but only 8 bytes remain",
+			name:      "TruncatedValues",
+		},
+		{
+			byteOrder: binary.BigEndian,
+			data:      []byte{0xFF, 0xFF, 0xFF, 0xFF},
+			err:       "table requires 65535 column names and 4294836225 values but only 0 bytes remain",
+			name:      "OversizedHeader",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var response, err = ParseSpatialFrequencyResponse(test.data, test.byteOrder)
+
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.str, response.String())
+		})
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	var tests = []struct {
+		data  []byte
+		err   string
+		major int
+		minor int
+		name  string
+		str   string
+	}{
+		{
+			data:  []byte("0232"),
+			major: 2,
+			minor: 32,
+			name:  "Exif",
+			str:   "2.32",
+		},
+		{
+			data:  []byte("0100"),
+			major: 1,
+			minor: 0,
+			name:  "Flashpix",
+			str:   "1.00",
+		},
+		{
+			data: []byte("023"),
+			err:  "version must be 4 bytes long, found 3 bytes",
+			name: "Truncated",
+		},
+		{
+			data: []byte("x232"),
+			err:  "invalid major version 'x2'",
+			name: "InvalidMajor",
+		},
+		{
+			data: []byte("02x2"),
+			err:  "invalid minor version 'x2'",
+			name: "InvalidMinor",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var version, err = ParseVersion(test.data)
+
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.major, version.Major())
+			require.Equal(t, test.minor, version.Minor())
+			require.Equal(t, test.str, version.String())
+		})
+	}
+}